@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+
+	"github.com/jwatson0/go/gosha256/sha2/analysis"
+)
+
+// runAvalanche implements "gosha256 avalanche"
+func runAvalanche(args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("avalanche", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	rounds := fs.Int("rounds", 64, "number of `rounds` of the compression function, 1-64")
+	sac := fs.Bool("sac", false, "compute the strict avalanche criterion matrix instead of per-round diffusion")
+	samples := fs.Int("samples", 100, "random blocks to sample for -sac")
+	seed := fs.Int64("seed", 1, "random seed for -sac")
+	isHex := fs.Bool("x", false, "block is hex encoded")
+	csvFile := fs.String("csv", "", "write the matrix as CSV to `file`")
+	pngFile := fs.String("png", "", "write the matrix as a heatmap PNG to `file`")
+	fs.Usage = func() {
+		fmt.Fprintf(errOut, "usage: gosha256 avalanche [flags] [block]\n"+
+			"the block is up to 64 bytes, zero filled; it is ignored with -sac\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *rounds < 1 || *rounds > 64 {
+		fmt.Fprintf(errOut, "avalanche: rounds must be 1-64\n")
+		return 2
+	}
+	if *samples < 1 {
+		fmt.Fprintf(errOut, "avalanche: samples must be at least 1\n")
+		return 2
+	}
+
+	var m [][]float64
+	var max float64
+	var prefix string
+	if *sac {
+		m = analysis.SAC(*rounds, *samples, rand.New(rand.NewSource(*seed)))
+		max = 1
+		prefix = "out"
+
+		// summarise how far the matrix is from the ideal 0.5
+		worst := 0.0
+		sum := 0.0
+		for _, row := range m {
+			for _, x := range row {
+				sum += x
+				if d := x - 0.5; d > worst {
+					worst = d
+				} else if -d > worst {
+					worst = -d
+				}
+			}
+		}
+		fmt.Fprintf(out, "rounds %d, samples %d: mean flip probability %.4f, worst deviation from 0.5 %.4f\n",
+			*rounds, *samples, sum/float64(len(m)*len(m[0])), worst)
+	} else {
+		var block [64]byte
+		b := []byte(strings.Join(fs.Args(), " "))
+		if *isHex {
+			var err error
+			if b, err = hex.DecodeString(string(b)); err != nil {
+				fmt.Fprintf(errOut, "avalanche: bad hex block: %v\n", err)
+				return 2
+			}
+		}
+		if len(b) > len(block) {
+			fmt.Fprintf(errOut, "avalanche: block is longer than 64 bytes\n")
+			return 2
+		}
+		copy(block[:], b)
+
+		d := analysis.Diffusion(block, *rounds)
+		m = analysis.Floats(d)
+		max = analysis.OutputBits
+		prefix = "round"
+		fmt.Fprintf(out, "round  mean changed state bits (of 256)\n")
+		for t, x := range analysis.MeanDiffusion(d) {
+			fmt.Fprintf(out, "%5d  %6.2f\n", t, x)
+		}
+	}
+
+	if *csvFile != "" {
+		if err := writeFile(*csvFile, func(w io.Writer) error { return analysis.WriteCSV(w, prefix, m) }); err != nil {
+			fmt.Fprintf(errOut, "avalanche: %v\n", err)
+			return 1
+		}
+	}
+	if *pngFile != "" {
+		if err := writeFile(*pngFile, func(w io.Writer) error { return analysis.WritePNG(w, m, max, 2) }); err != nil {
+			fmt.Fprintf(errOut, "avalanche: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeFile creates name and writes it with fn
+func writeFile(name string, fn func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/filecache"
+)
+
+// runDaemon implements "gosha256 daemon", serving hashes over HTTP until
+// ctx is cancelled
+func runDaemon(ctx context.Context, args []string, errOut io.Writer) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	addr := fs.String("addr", "localhost:8257", "listen on TCP `address`")
+	socket := fs.String("socket", "", "listen on the Unix socket at `path` instead")
+	workers := fs.Int("j", runtime.NumCPU(), "hash at most `n` bodies or files at a time")
+	entries := fs.Int("cache-entries", 1<<20, "remember the digests of at most `n` files")
+	grace := fs.Duration("grace", 30*time.Second, "on shutdown, wait up to `duration` for requests to finish")
+	cache := fs.String("cache", "", "also keep file digests in the cache file at `path`, shared with gosha256 -cache")
+	fs.Usage = func() {
+		fmt.Fprintf(errOut, "usage: gosha256 daemon [-addr address | -socket path] [-j n] [-cache path]\n")
+		fmt.Fprintf(errOut, "\nPOST /hash hashes the request body, GET /file?path=/abs/path a file,\nand GET /metrics reports counters in the Prometheus text format.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *workers < 1 {
+		*workers = 1
+	}
+
+	var l net.Listener
+	var err error
+	if *socket != "" {
+		// a socket left by a daemon that died is in the way, but one that
+		// still answers belongs to a daemon that is running
+		if fi, err := os.Lstat(*socket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if c, err := net.DialTimeout("unix", *socket, time.Second); err == nil {
+				c.Close()
+				fmt.Fprintf(errOut, "daemon: a daemon is already listening on %s\n", *socket)
+				return 1
+			}
+			os.Remove(*socket)
+		}
+		l, err = net.Listen("unix", *socket)
+		if err == nil {
+			err = os.Chmod(*socket, 0660)
+		}
+	} else {
+		l, err = net.Listen("tcp", *addr)
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "daemon: %v\n", err)
+		return 1
+	}
+
+	d := newDaemon(*workers, *entries)
+	if *cache != "" {
+		c, err := filecache.Open(*cache)
+		if err != nil {
+			l.Close()
+			fmt.Fprintf(errOut, "daemon: %v\n", err)
+			return 1
+		}
+		defer c.Close()
+		d.disk = c
+	}
+	srv := &http.Server{
+		Handler:           d.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(l)
+	}()
+	fmt.Fprintf(errOut, "gosha256 daemon listening on %s\n", l.Addr())
+
+	select {
+	case err = <-done:
+		fmt.Fprintf(errOut, "daemon: %v\n", err)
+		return 1
+	case <-ctx.Done():
+	}
+	fmt.Fprintf(errOut, "gosha256 daemon shutting down\n")
+	sctx, cancel := context.WithTimeout(context.Background(), *grace)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		fmt.Fprintf(errOut, "daemon: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+type cacheEntry struct {
+	digest [32]byte
+	size   int64
+}
+
+// call is a file being hashed, which other requests for it wait for
+type call struct {
+	done chan struct{}
+	e    cacheEntry
+	err  error
+}
+
+// daemon holds the state shared by requests
+type daemon struct {
+	sem        chan struct{} // one token per hash in progress
+	maxEntries int
+	disk       *filecache.Cache // if not nil, consulted on a miss
+
+	mu    sync.Mutex
+	cache map[filecache.Key]cacheEntry
+	calls map[filecache.Key]*call
+
+	// metrics, accessed atomically
+	requests, hashBytes, cacheHits, cacheMisses, errs, inFlight int64
+}
+
+func newDaemon(workers, maxEntries int) *daemon {
+	return &daemon{
+		sem:        make(chan struct{}, workers),
+		maxEntries: maxEntries,
+		cache:      make(map[filecache.Key]cacheEntry),
+		calls:      make(map[filecache.Key]*call),
+	}
+}
+
+func (d *daemon) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hash", d.serveHash)
+	mux.HandleFunc("/file", d.serveFile)
+	mux.HandleFunc("/metrics", d.serveMetrics)
+	return mux
+}
+
+// reply is the JSON answer to /hash and /file
+type reply struct {
+	Path   string `json:"path,omitempty"`
+	Size   int64  `json:"size"`
+	Digest string `json:"digest"`
+	Cached bool   `json:"cached,omitempty"`
+}
+
+func (d *daemon) fail(w http.ResponseWriter, code int, err error) {
+	atomic.AddInt64(&d.errs, 1)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+func (d *daemon) send(w http.ResponseWriter, r reply) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r)
+}
+
+// acquire waits for a free worker, or for ctx to be cancelled
+func (d *daemon) acquire(ctx context.Context) error {
+	select {
+	case d.sem <- struct{}{}:
+		atomic.AddInt64(&d.inFlight, 1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *daemon) release() {
+	atomic.AddInt64(&d.inFlight, -1)
+	<-d.sem
+}
+
+// serveHash hashes the request body as it arrives
+func (d *daemon) serveHash(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&d.requests, 1)
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		w.Header().Set("Allow", "POST, PUT")
+		d.fail(w, http.StatusMethodNotAllowed, errors.New("use POST"))
+		return
+	}
+	if err := d.acquire(r.Context()); err != nil {
+		d.fail(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	sum, n, err := sha2.SumReader(r.Context(), r.Body)
+	d.release()
+	atomic.AddInt64(&d.hashBytes, n)
+	if err != nil {
+		d.fail(w, http.StatusBadRequest, err)
+		return
+	}
+	d.send(w, reply{Size: n, Digest: hex.EncodeToString(sum[:])})
+}
+
+// serveFile hashes the file named by the path parameter, which must be
+// absolute, or answers from the cache if the file is unchanged
+func (d *daemon) serveFile(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&d.requests, 1)
+	path := r.FormValue("path")
+	if !filepath.IsAbs(path) {
+		d.fail(w, http.StatusBadRequest, errors.New("path must be absolute"))
+		return
+	}
+	e, cached, err := d.hashFile(r.Context(), path)
+	switch {
+	case os.IsNotExist(err):
+		d.fail(w, http.StatusNotFound, err)
+	case errors.Is(err, errChanged):
+		d.fail(w, http.StatusConflict, err)
+	case err != nil:
+		d.fail(w, http.StatusInternalServerError, err)
+	default:
+		d.send(w, reply{Path: path, Size: e.size, Digest: hex.EncodeToString(e.digest[:]), Cached: cached})
+	}
+}
+
+var errChanged = errors.New("file changed while it was hashed")
+
+// hashFile returns the digest of the file at path, from the cache if it
+// can.  Requests for a file already being hashed wait for that result.
+func (d *daemon) hashFile(ctx context.Context, path string) (e cacheEntry, cached bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return e, false, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return e, false, err
+	}
+	if !fi.Mode().IsRegular() {
+		return e, false, fmt.Errorf("%s: not a regular file", path)
+	}
+	key, ok := filecache.KeyOf(fi)
+	if !ok {
+		atomic.AddInt64(&d.cacheMisses, 1)
+		e, err = d.hash(ctx, f)
+		return e, false, err
+	}
+
+	for {
+		d.mu.Lock()
+		if e, ok := d.cache[key]; ok {
+			d.mu.Unlock()
+			atomic.AddInt64(&d.cacheHits, 1)
+			return e, true, nil
+		}
+		c, busy := d.calls[key]
+		if !busy {
+			c = &call{done: make(chan struct{})}
+			d.calls[key] = c
+		}
+		d.mu.Unlock()
+
+		if busy {
+			select {
+			case <-c.done:
+			case <-ctx.Done():
+				return e, false, ctx.Err()
+			}
+			if c.err == nil {
+				atomic.AddInt64(&d.cacheHits, 1)
+				return c.e, true, nil
+			}
+			if ctx.Err() == nil && (errors.Is(c.err, context.Canceled) || errors.Is(c.err, context.DeadlineExceeded)) {
+				continue // the request doing the work went away, try again
+			}
+			return e, false, c.err
+		}
+
+		if d.disk != nil {
+			if sum, ok := d.disk.Get(key); ok {
+				c.e = cacheEntry{digest: sum, size: key.Size}
+				d.finish(key, c)
+				atomic.AddInt64(&d.cacheHits, 1)
+				return c.e, true, nil
+			}
+		}
+		atomic.AddInt64(&d.cacheMisses, 1)
+		c.e, c.err = d.hash(ctx, f)
+		if c.err == nil {
+			// cache only if the file didn't change under us
+			if fi, err := f.Stat(); err != nil {
+				c.err = err
+			} else if k, _ := filecache.KeyOf(fi); k != key {
+				c.err = errChanged
+			}
+		}
+		if c.err == nil && d.disk != nil {
+			d.disk.Put(key, c.e.digest)
+		}
+		d.finish(key, c)
+		return c.e, false, c.err
+	}
+}
+
+// finish caches the result of c, if it succeeded, and wakes its waiters
+func (d *daemon) finish(key filecache.Key, c *call) {
+	d.mu.Lock()
+	delete(d.calls, key)
+	if c.err == nil {
+		d.store(key, c.e)
+	}
+	d.mu.Unlock()
+	close(c.done)
+}
+
+// store adds an entry, with d.mu held, evicting an arbitrary one when full
+func (d *daemon) store(key filecache.Key, e cacheEntry) {
+	if len(d.cache) >= d.maxEntries {
+		for k := range d.cache {
+			delete(d.cache, k)
+			break
+		}
+	}
+	d.cache[key] = e
+}
+
+func (d *daemon) hash(ctx context.Context, r io.Reader) (cacheEntry, error) {
+	if err := d.acquire(ctx); err != nil {
+		return cacheEntry{}, err
+	}
+	defer d.release()
+	sum, n, err := sha2.SumReader(ctx, r)
+	atomic.AddInt64(&d.hashBytes, n)
+	return cacheEntry{digest: sum, size: n}, err
+}
+
+// serveMetrics writes the counters in the Prometheus text format
+func (d *daemon) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	entries := len(d.cache)
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range []struct {
+		name, kind, help string
+		value            int64
+	}{
+		{"gosha256_requests_total", "counter", "Requests to /hash and /file.", atomic.LoadInt64(&d.requests)},
+		{"gosha256_errors_total", "counter", "Requests that failed.", atomic.LoadInt64(&d.errs)},
+		{"gosha256_hashed_bytes_total", "counter", "Bytes hashed.", atomic.LoadInt64(&d.hashBytes)},
+		{"gosha256_cache_hits_total", "counter", "File digests answered from the cache.", atomic.LoadInt64(&d.cacheHits)},
+		{"gosha256_cache_misses_total", "counter", "File digests computed.", atomic.LoadInt64(&d.cacheMisses)},
+		{"gosha256_cache_entries", "gauge", "Files in the cache.", int64(entries)},
+		{"gosha256_hashes_in_flight", "gauge", "Hashes in progress.", atomic.LoadInt64(&d.inFlight)},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", m.name, m.help, m.name, m.kind, m.name, m.value)
+	}
+}
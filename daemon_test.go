@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// replies returns a function decoding the reply to a request
+func replies(t *testing.T) func(*http.Response, error) (int, reply) {
+	return func(resp *http.Response, err error) (int, reply) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var r reply
+		json.NewDecoder(resp.Body).Decode(&r)
+		return resp.StatusCode, r
+	}
+}
+
+func TestDaemon(t *testing.T) {
+	dir := tree(t)
+	defer os.RemoveAll(dir)
+	srv := httptest.NewServer(newDaemon(2, 10).handler())
+	defer srv.Close()
+	getReply := replies(t)
+
+	code, r := getReply(http.Post(srv.URL+"/hash", "", strings.NewReader("abc")))
+	if code != 200 || r.Size != 3 || r.Digest != fmt.Sprintf("%x", sha256.Sum256([]byte("abc"))) {
+		t.Errorf("POST /hash abc => %d %+v", code, r)
+	}
+
+	file := filepath.Join(dir, "b")
+	want := fmt.Sprintf("%x", sha256.Sum256([]byte("bee")))
+	for i, cached := range []bool{false, true} {
+		code, r = getReply(http.Get(srv.URL + "/file?path=" + file))
+		if code != 200 || r.Digest != want || r.Size != 3 || r.Cached != cached {
+			t.Errorf("GET /file %d => %d %+v, want %s cached %v", i, code, r, want, cached)
+		}
+	}
+
+	// a change of mtime is a miss
+	ioutil.WriteFile(file, []byte("bees"), 0644)
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(file, later, later)
+	code, r = getReply(http.Get(srv.URL + "/file?path=" + file))
+	if code != 200 || r.Cached || r.Digest != fmt.Sprintf("%x", sha256.Sum256([]byte("bees"))) {
+		t.Errorf("GET /file after a change => %d %+v", code, r)
+	}
+
+	for path, want := range map[string]int{
+		"b":                           http.StatusBadRequest,
+		filepath.Join(dir, "missing"): http.StatusNotFound,
+		dir:                           http.StatusInternalServerError,
+	} {
+		if code, _ := getReply(http.Get(srv.URL + "/file?path=" + path)); code != want {
+			t.Errorf("GET /file?path=%s => %d, want %d", path, code, want)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, line := range []string{
+		"gosha256_requests_total 7",
+		"gosha256_errors_total 3",
+		"gosha256_cache_hits_total 1",
+		"gosha256_cache_misses_total 2",
+		"gosha256_hashed_bytes_total 10",
+		"# TYPE gosha256_cache_entries gauge",
+	} {
+		if !strings.Contains(string(b), line+"\n") {
+			t.Errorf("GET /metrics is missing %q:\n%s", line, b)
+		}
+	}
+}
+
+func TestDaemonSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "sock")
+
+	// a socket left by a daemon that died
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rc := make(chan int)
+	go func() {
+		rc <- runDaemon(ctx, []string{"-socket", socket}, ioutil.Discard)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	getReply := replies(t)
+	var resp *http.Response
+	for i := 0; i < 100; i++ {
+		resp, err = client.Post("http://daemon/hash", "", strings.NewReader(""))
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if code, r := getReply(resp, err); code != 200 || r.Digest != fmt.Sprintf("%x", sha256.Sum256(nil)) {
+		t.Errorf("POST /hash over the socket => %d %+v", code, r)
+	}
+	if got := runDaemon(ctx, []string{"-socket", socket}, ioutil.Discard); got != 1 {
+		t.Errorf("second runDaemon on the same socket returned %d, want 1", got)
+	}
+
+	cancel()
+	if got := <-rc; got != 0 {
+		t.Errorf("runDaemon after shutdown returned %d, want 0", got)
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket left behind after shutdown: %v", err)
+	}
+}
+
+func TestDaemonConcurrent(t *testing.T) {
+	dir := tree(t)
+	defer os.RemoveAll(dir)
+	d := newDaemon(1, 10)
+	srv := httptest.NewServer(d.handler())
+	defer srv.Close()
+
+	// requests for the same file share one hash
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(srv.URL + "/file?path=" + filepath.Join(dir, "a"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != 200 {
+				t.Errorf("GET /file => %s", resp.Status)
+			}
+		}()
+	}
+	wg.Wait()
+	if d.cacheMisses != 1 || d.cacheHits != 7 {
+		t.Errorf("8 concurrent requests made %d misses and %d hits, want 1 and 7", d.cacheMisses, d.cacheHits)
+	}
+}
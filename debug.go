@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jwatson0/go/gosha256/sha2/explain"
+)
+
+const debugHelp = `commands:
+  next, n            step to the next round (and on to the next block)
+  round T, r T       go to round T (0-63) of the current block
+  block N, b N       go to round 0 of block N (from 1)
+  print X, p X       print X, one of:
+                       w[T]  message schedule word and its σ0/σ1 terms
+                       k[T]  round constant
+                       a..h  working variables after the round
+                       t1 t2 ch maj S0 S1 (or Σ0 Σ1)
+                       H     intermediate hash value before the block
+                       state all working variables
+  show, s            show the current round
+  help               this text
+  quit, q            exit
+an empty line repeats the last command
+`
+
+// debugger steps through a recorded SHA-256 computation
+type debugger struct {
+	r     *explain.Report
+	block int // index in r.Blocks
+	t     int // round
+	out   io.Writer
+}
+
+// runDebug implements "gosha256 debug", reading commands from in
+func runDebug(args []string, in io.Reader, out io.Writer) int {
+	fs := flag.NewFlagSet("debug", flag.ContinueOnError)
+	fs.SetOutput(out)
+	isHex := fs.Bool("x", false, "message is hex encoded")
+	file := fs.String("f", "", "read the message from `file`")
+	fs.Usage = func() {
+		fmt.Fprintf(out, "usage: gosha256 debug [-x] [-f file | message]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var m []byte
+	switch {
+	case *file != "":
+		b, err := ioutil.ReadFile(*file)
+		if err != nil {
+			fmt.Fprintf(out, "debug: %v\n", err)
+			return 1
+		}
+		m = b
+	default:
+		m = []byte(strings.Join(fs.Args(), " "))
+	}
+	if *isHex {
+		b, err := hex.DecodeString(strings.TrimSpace(string(m)))
+		if err != nil {
+			fmt.Fprintf(out, "debug: bad hex message: %v\n", err)
+			return 1
+		}
+		m = b
+	}
+
+	d := &debugger{r: explain.New(m), out: out}
+	fmt.Fprintf(out, "message %s (%d bits, %d blocks), type help for commands\n", strconv.QuoteToASCII(string(m)), len(m)*8, len(d.r.Blocks))
+	d.show()
+
+	prompt := in == os.Stdin
+	s := bufio.NewScanner(in)
+	last := ""
+	for {
+		if prompt {
+			fmt.Fprint(out, "(sha256) ")
+		}
+		if !s.Scan() {
+			break
+		}
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			line = last
+		}
+		last = line
+		if line == "" {
+			continue
+		}
+		if !d.exec(strings.Fields(line)) {
+			break
+		}
+	}
+	return 0
+}
+
+// exec runs one command, returning false to quit
+func (d *debugger) exec(cmd []string) bool {
+	arg := ""
+	if len(cmd) > 1 {
+		arg = strings.Join(cmd[1:], "")
+	}
+	switch strings.ToLower(cmd[0]) {
+	case "next", "n":
+		d.next()
+	case "round", "r":
+		t, err := strconv.Atoi(arg)
+		if err != nil || t < 0 || t > 63 {
+			fmt.Fprintf(d.out, "round must be 0-63\n")
+			return true
+		}
+		d.t = t
+		d.show()
+	case "block", "b":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > len(d.r.Blocks) {
+			fmt.Fprintf(d.out, "block must be 1-%d\n", len(d.r.Blocks))
+			return true
+		}
+		d.block = n - 1
+		d.t = 0
+		d.show()
+	case "print", "p":
+		d.print(arg)
+	case "show", "s":
+		d.show()
+	case "help", "h", "?":
+		fmt.Fprint(d.out, debugHelp)
+	case "quit", "q", "exit":
+		return false
+	default:
+		fmt.Fprintf(d.out, "unknown command %q, type help for commands\n", cmd[0])
+	}
+	return true
+}
+
+func (d *debugger) next() {
+	b := &d.r.Blocks[d.block]
+	if d.t < 63 {
+		d.t++
+		d.show()
+		return
+	}
+	fmt.Fprintf(d.out, "block %d done\n", d.block+1)
+	for j := range b.Next {
+		fmt.Fprintf(d.out, "  H[%d] = %8.8X + %8.8X = %8.8X\n", j, b.Work[j], b.Prev[j], b.Next[j])
+	}
+	if d.block+1 == len(d.r.Blocks) {
+		fmt.Fprintf(d.out, "Message Digest is %x\n", d.r.Digest)
+		return
+	}
+	d.block++
+	d.t = 0
+	d.show()
+}
+
+// before returns the working variables going into the current round
+func (d *debugger) before() [8]uint32 {
+	b := &d.r.Blocks[d.block]
+	if d.t == 0 {
+		return b.Prev
+	}
+	return b.Rounds[d.t-1].State
+}
+
+func (d *debugger) show() {
+	r := d.r.Blocks[d.block].Rounds[d.t]
+	v := d.before()
+	fmt.Fprintf(d.out, "block %d of %d, t=%d\n", d.block+1, len(d.r.Blocks), d.t)
+	fmt.Fprintf(d.out, "  W[%d] = %8.8X  K[%d] = %8.8X\n", d.t, r.W, d.t, r.K)
+	fmt.Fprintf(d.out, "  T1 = h + Σ1(e) + Ch(e,f,g) + K + W = %8.8X + %8.8X + %8.8X + %8.8X + %8.8X = %8.8X\n", v[7], r.UpperSigma1, r.Ch, r.K, r.W, r.T1)
+	fmt.Fprintf(d.out, "  T2 = Σ0(a) + Maj(a,b,c) = %8.8X + %8.8X = %8.8X\n", r.UpperSigma0, r.Maj, r.T2)
+	fmt.Fprintf(d.out, "         A        B        C        D        E        F        G        H\n")
+	fmt.Fprintf(d.out, "  in:  %s\n", stateString(v))
+	fmt.Fprintf(d.out, "  out: %s\n", stateString(r.State))
+}
+
+func (d *debugger) print(x string) {
+	b := &d.r.Blocks[d.block]
+	r := b.Rounds[d.t]
+	name := strings.ToLower(x)
+
+	// indexed names: w[20], k[3]
+	if i := strings.IndexByte(name, '['); i > 0 && strings.HasSuffix(name, "]") {
+		t, err := strconv.Atoi(name[i+1 : len(name)-1])
+		if err != nil || t < 0 || t > 63 {
+			fmt.Fprintf(d.out, "index must be 0-63\n")
+			return
+		}
+		switch name[:i] {
+		case "w":
+			fmt.Fprintf(d.out, "W[%d] = %8.8X\n", t, b.W[t])
+			if t >= 16 {
+				rt := b.Rounds[t]
+				fmt.Fprintf(d.out, "  = σ1(W[%d]) + W[%d] + σ0(W[%d]) + W[%d]\n", t-2, t-7, t-15, t-16)
+				fmt.Fprintf(d.out, "  = %8.8X + %8.8X + %8.8X + %8.8X\n", rt.LowerSigma1, b.W[t-7], rt.LowerSigma0, b.W[t-16])
+			}
+			return
+		case "k":
+			fmt.Fprintf(d.out, "K[%d] = %8.8X\n", t, b.Rounds[t].K)
+			return
+		}
+	}
+
+	if len(x) == 1 && x[0] >= 'a' && x[0] <= 'h' {
+		fmt.Fprintf(d.out, "%s = %8.8X\n", x, r.State[x[0]-'a'])
+		return
+	}
+
+	switch x {
+	case "t1", "T1":
+		fmt.Fprintf(d.out, "T1 = %8.8X\n", r.T1)
+	case "t2", "T2":
+		fmt.Fprintf(d.out, "T2 = %8.8X\n", r.T2)
+	case "ch", "Ch":
+		fmt.Fprintf(d.out, "Ch(e,f,g) = %8.8X\n", r.Ch)
+	case "maj", "Maj":
+		fmt.Fprintf(d.out, "Maj(a,b,c) = %8.8X\n", r.Maj)
+	case "S0", "Σ0":
+		fmt.Fprintf(d.out, "Σ0(a) = %8.8X\n", r.UpperSigma0)
+	case "S1", "Σ1":
+		fmt.Fprintf(d.out, "Σ1(e) = %8.8X\n", r.UpperSigma1)
+	case "s0", "σ0", "s1", "σ1":
+		if d.t < 16 {
+			fmt.Fprintf(d.out, "W[%d] is a word of the block, σ0/σ1 start at t=16\n", d.t)
+		} else if x == "s0" || x == "σ0" {
+			fmt.Fprintf(d.out, "σ0(W[%d]) = %8.8X\n", d.t-15, r.LowerSigma0)
+		} else {
+			fmt.Fprintf(d.out, "σ1(W[%d]) = %8.8X\n", d.t-2, r.LowerSigma1)
+		}
+	case "H":
+		fmt.Fprintf(d.out, "H = %s\n", stateString(b.Prev))
+	case "state":
+		fmt.Fprintf(d.out, "%s\n", stateString(r.State))
+	case "":
+		fmt.Fprintf(d.out, "print what? type help for names\n")
+	default:
+		fmt.Fprintf(d.out, "unknown name %q, type help for names\n", x)
+	}
+}
+
+func stateString(s [8]uint32) string {
+	w := make([]string, len(s))
+	for i, x := range s {
+		w[i] = fmt.Sprintf("%8.8X", x)
+	}
+	return strings.Join(w, " ")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDebug(t *testing.T) {
+	script := strings.Join([]string{
+		"round 63",
+		"next",
+		"print w[16]",
+		"print ch",
+		"p a",
+		"block 1",
+		"",
+		"print H",
+		"bogus",
+		"quit",
+		"next",
+	}, "\n")
+	var out bytes.Buffer
+	if rc := runDebug([]string{"abc"}, strings.NewReader(script), &out); rc != 0 {
+		t.Fatalf("runDebug returned %d", rc)
+	}
+	// values from the NIST SHA-256 "abc" example
+	want := []string{
+		"block 1 of 1, t=0\n",
+		"  out: 5D6AEBCD 6A09E667 BB67AE85 3C6EF372 FA2A4622 510E527F 9B05688C 1F83D9AB\n",
+		"  out: 506E3058 D39A2165 04D24D6C B85E2CE9 5EF50F24 FB121210 948D25B6 961F4894\n",
+		"  H[0] = 506E3058 + 6A09E667 = BA7816BF\n",
+		"Message Digest is ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad\n",
+		"W[16] = 61626380\n",
+		"  = σ1(W[14]) + W[9] + σ0(W[1]) + W[0]\n",
+		"a = 506E3058\n",
+		"H = 6A09E667 BB67AE85 3C6EF372 A54FF53A 510E527F 9B05688C 1F83D9AB 5BE0CD19\n",
+		"unknown command \"bogus\"",
+	}
+	got := out.String()
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("debug output missing %q", w)
+		}
+	}
+	// the empty line repeats "block 1"
+	if n := strings.Count(got, "block 1 of 1, t=0\n"); n != 3 {
+		t.Errorf("debug showed round 0 %d times, want 3", n)
+	}
+}
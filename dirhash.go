@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jwatson0/go/gosha256/sha2/dirhash"
+)
+
+// runDirhash implements "gosha256 dirhash", printing the h1: hash of each
+// directory or zip archive
+func runDirhash(args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("dirhash", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	prefix := fs.String("prefix", "", "name files in a directory as `prefix`/path, e.g. example.com/m@v1.0.0")
+	fs.Usage = func() {
+		fmt.Fprintf(errOut, "usage: gosha256 dirhash [-prefix p] dir|file.zip ...\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, p := range fs.Args() {
+		var h string
+		fi, err := os.Stat(p)
+		if err == nil {
+			if fi.IsDir() {
+				h, err = dirhash.HashDir(p, *prefix, dirhash.DefaultHash)
+			} else {
+				h, err = dirhash.HashZip(p, dirhash.DefaultHash)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(errOut, "gosha256: %v\n", err)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(out, "%s  %s\n", h, p)
+	}
+	return status
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/filecache"
+	"github.com/jwatson0/go/gosha256/sha2/treehash"
+)
+
+// result of hashing one file
+type result struct {
+	path    string
+	size    int64
+	modTime time.Time // zero if not a file
+	digest  [32]byte
+	elapsed time.Duration
+	err     error
+}
+
+// hasher holds what hashing files needs besides the files
+type hasher struct {
+	stdin    io.Reader        // the file named -
+	progress *sha2.Progress   // counts the bytes hashed, if not nil
+	cache    *filecache.Cache // digests of unchanged files, if not nil
+	verify   float64          // fraction of files found in the cache to hash anyway, to check it
+
+	tree        *treehash.Scheme // hash with this scheme instead, if not nil
+	treeWorkers int              // goroutines hashing the chunks of a file
+}
+
+// hashFiles hashes files with a pool of workers goroutines and calls emit
+// with each result, in the order of files.  Once ctx is cancelled no more
+// files are started, and the ones being hashed stop with an error.
+func (h *hasher) hashFiles(ctx context.Context, files []string, workers int, emit func(result)) {
+	type indexed struct {
+		i int
+		r result
+	}
+	jobs := make(chan int)
+	done := make(chan indexed)
+
+	go func() {
+		defer close(jobs)
+		for i := range files {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	finished := make(chan bool)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				done <- indexed{i, h.hashFile(ctx, files[i])}
+			}
+			finished <- true
+		}()
+	}
+	go func() {
+		for w := 0; w < workers; w++ {
+			<-finished
+		}
+		close(done)
+	}()
+
+	// hold results that finish early until the ones before them are out
+	pending := make(map[int]result)
+	next := 0
+	for d := range done {
+		pending[d.i] = d.r
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			emit(r)
+			delete(pending, next)
+			next++
+		}
+	}
+}
+
+// hashFile hashes the file at path using buf, or looks it up in the cache
+func (h *hasher) hashFile(ctx context.Context, path string) result {
+	if path == "-" {
+		return h.hashReader(ctx, path, h.stdin)
+	}
+	start := time.Now()
+	f, err := os.Open(path)
+	if err != nil {
+		return result{path: path, err: err}
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return result{path: path, err: err}
+	}
+	var key filecache.Key
+	keyed := false
+	if h.cache != nil && fi.Mode().IsRegular() {
+		key, keyed = filecache.KeyOf(fi)
+	}
+	cached, hit := [32]byte{}, false
+	if keyed {
+		cached, hit = h.cache.Get(key)
+	}
+	if hit && (h.verify <= 0 || rand.Float64() >= h.verify) {
+		if h.progress != nil {
+			h.progress.Add(fi.Size())
+		}
+		return result{path: path, size: fi.Size(), modTime: fi.ModTime(), digest: cached, elapsed: time.Since(start)}
+	}
+
+	r := h.hashReader(ctx, path, f)
+	if fi.Mode().IsRegular() {
+		r.modTime = fi.ModTime()
+	}
+	r.elapsed = time.Since(start)
+	if r.err != nil || !keyed {
+		return r
+	}
+	if hit && r.digest != cached {
+		r.err = fmt.Errorf("%s: cached digest %x is wrong", path, cached)
+	}
+	// cache only if the file didn't change while it was hashed
+	if fi, err := f.Stat(); err == nil {
+		if k, _ := filecache.KeyOf(fi); k == key {
+			if err := h.cache.Put(key, r.digest); err != nil && r.err == nil {
+				r.err = err
+			}
+		}
+	}
+	return r
+}
+
+// hashReader hashes everything read from r with the tree scheme, if any,
+// or else with SHA-256
+func (h *hasher) hashReader(ctx context.Context, path string, r io.Reader) result {
+	if h.tree == nil {
+		return hashSHA256(ctx, path, r, h.progress)
+	}
+	start := time.Now()
+	if h.progress != nil {
+		r = io.TeeReader(r, h.progress.Writer(ioutil.Discard))
+	}
+	sum, n, err := h.tree.SumReader(ctx, r, h.treeWorkers)
+	if err != nil {
+		if err == ctx.Err() {
+			err = fmt.Errorf("%s: %v", path, err)
+		}
+		return result{path: path, err: err}
+	}
+	return result{path: path, size: n, digest: sum, elapsed: time.Since(start)}
+}
+
+// hashSHA256 hashes everything read from r with sha2.SumReader, which
+// stops once ctx is cancelled, counting the bytes in p if it isn't nil
+func hashSHA256(ctx context.Context, path string, r io.Reader, p *sha2.Progress) result {
+	start := time.Now()
+	if p != nil {
+		r = io.TeeReader(r, p.Writer(ioutil.Discard))
+	}
+	sum, n, err := sha2.SumReader(ctx, r)
+	if err != nil {
+		if err == ctx.Err() {
+			err = fmt.Errorf("%s: %v", path, err)
+		}
+		return result{path: path, err: err}
+	}
+	return result{path: path, size: n, digest: sum, elapsed: time.Since(start)}
+}
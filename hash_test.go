@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwatson0/go/gosha256/sha2/filecache"
+)
+
+// tree creates a directory tree for the tests and returns its root
+func tree(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gosha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"b":       "bee",
+		"a":       "",
+		"d/c":     strings.Repeat("c", 100000),
+		"d/e/f":   "f",
+		"d.txt":   "d.txt",
+		"g/h\\ij": "odd name",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// links are skipped unless followed, and a loop is reported
+	os.Symlink("b", filepath.Join(dir, "link"))
+	os.Symlink("..", filepath.Join(dir, "d", "up"))
+	return dir
+}
+
+func sumLine(t *testing.T, dir, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if strings.Contains(p, "\\") {
+		return fmt.Sprintf("\\%x  %s\n", sha256.Sum256(b), strings.Replace(p, "\\", "\\\\", -1))
+	}
+	return fmt.Sprintf("%x  %s\n", sha256.Sum256(b), p)
+}
+
+func TestHashRecursive(t *testing.T) {
+	dir := tree(t)
+	defer os.RemoveAll(dir)
+
+	for _, j := range []string{"1", "3", "16"} {
+		var out, errOut bytes.Buffer
+		rc := runHash(context.Background(), []string{"-r", "-j", j, dir, filepath.Join(dir, "missing"), filepath.Join(dir, "b")}, nil, &out, &errOut)
+		if rc != 1 {
+			t.Errorf("runHash -j %s returned %d, want 1 for the missing file", j, rc)
+		}
+		want := ""
+		for _, name := range []string{"a", "b", "d.txt", "d/c", "d/e/f", "g/h\\ij", "b"} {
+			want += sumLine(t, dir, name)
+		}
+		if out.String() != want {
+			t.Errorf("runHash -r -j %s printed\n%s\nwant\n%s", j, out.String(), want)
+		}
+		if !strings.Contains(errOut.String(), "missing") || strings.Contains(errOut.String(), "loop") {
+			t.Errorf("runHash -r -j %s reported %q", j, errOut.String())
+		}
+	}
+}
+
+func TestHashFollow(t *testing.T) {
+	dir := tree(t)
+	defer os.RemoveAll(dir)
+
+	var out, errOut bytes.Buffer
+	runHash(context.Background(), []string{"-r", "-L", dir}, nil, &out, &errOut)
+	if !strings.Contains(out.String(), sumLine(t, dir, "link")) {
+		t.Errorf("runHash -r -L did not follow the link:\n%s", out.String())
+	}
+	if !strings.Contains(errOut.String(), "directory loop") {
+		t.Errorf("runHash -r -L did not report the loop: %q", errOut.String())
+	}
+}
+
+func TestHashStdin(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if rc := runHash(context.Background(), nil, strings.NewReader("abc"), &out, &errOut); rc != 0 {
+		t.Fatalf("runHash returned %d: %s", rc, errOut.String())
+	}
+	if want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  -\n"; out.String() != want {
+		t.Errorf("runHash of stdin printed %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	dir := tree(t)
+	defer os.RemoveAll(dir)
+	runHash(context.Background(), []string{dir}, nil, &out, &errOut)
+	if out.Len() != 0 || !strings.Contains(errOut.String(), "is a directory") {
+		t.Errorf("runHash of a directory without -r printed %q, %q", out.String(), errOut.String())
+	}
+}
+
+func TestHashEncoding(t *testing.T) {
+	var out, errOut bytes.Buffer
+	runHash(context.Background(), []string{"-e", "sri"}, strings.NewReader("abc"), &out, &errOut)
+	if want := "sha256-ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0=  -\n"; out.String() != want {
+		t.Errorf("runHash -e sri printed %q, want %q", out.String(), want)
+	}
+	if rc := runHash(context.Background(), []string{"-e", "rot13"}, strings.NewReader("abc"), &out, &errOut); rc != 2 {
+		t.Errorf("runHash -e rot13 returned %d, want 2", rc)
+	}
+}
+
+func TestHashRecords(t *testing.T) {
+	dir := tree(t)
+	defer os.RemoveAll(dir)
+	missing := filepath.Join(dir, "missing")
+
+	var out, errOut bytes.Buffer
+	if rc := runHash(context.Background(), []string{"-r", "-o", "json", dir, missing}, nil, &out, &errOut); rc != 1 {
+		t.Errorf("runHash -o json returned %d, want 1", rc)
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("runHash -o json wrote errors to stderr: %q", errOut.String())
+	}
+	var recs []record
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var r record
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, r)
+	}
+	if len(recs) != 7 {
+		t.Fatalf("runHash -o json wrote %d records, want 7", len(recs))
+	}
+	// the missing file is reported while collecting, before the hashes
+	if recs[0].Path != missing || recs[0].Error == "" || recs[0].Digest != "" {
+		t.Errorf("runHash -o json error record %+v", recs[0])
+	}
+	name := filepath.Join(dir, "g", "h\\ij")
+	if r := recs[6]; r.Path != name || r.Size != 8 || r.Mtime == "" || r.Algorithm != "sha256" || r.Digest != fmt.Sprintf("%x", sha256.Sum256([]byte("odd name"))) {
+		t.Errorf("runHash -o json record %+v", r)
+	}
+
+	out.Reset()
+	runHash(context.Background(), []string{"-o", "csv", "-e", "oci", filepath.Join(dir, "b"), missing}, nil, &out, &errOut)
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != "path,size,mtime,digest,algorithm,elapsed,error" {
+		t.Fatalf("runHash -o csv wrote %q", rows)
+	}
+	if rows[1][0] != missing || rows[1][3] != "" || rows[1][6] == "" {
+		t.Errorf("runHash -o csv error row %q", rows[1])
+	}
+	if rows[2][1] != "3" || rows[2][3] != fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("bee"))) || rows[2][6] != "" {
+		t.Errorf("runHash -o csv row %q", rows[2])
+	}
+
+	if rc := runHash(context.Background(), []string{"-o", "xml"}, nil, &out, &errOut); rc != 2 {
+		t.Errorf("runHash -o xml returned %d, want 2", rc)
+	}
+}
+
+func TestHashProgress(t *testing.T) {
+	dir := tree(t)
+	defer os.RemoveAll(dir)
+
+	var out, errOut bytes.Buffer
+	if rc := runHash(context.Background(), []string{"-progress", "-r", dir}, nil, &out, &errOut); rc != 0 {
+		t.Fatalf("runHash -progress returned %d: %s", rc, errOut.String())
+	}
+	if s := errOut.String(); !strings.Contains(s, "] 100%") || !strings.Contains(s, "ETA") || !strings.Contains(s, "\nhashed ") {
+		t.Errorf("runHash -progress wrote %q", s)
+	}
+
+	// with stdin the size isn't known
+	errOut.Reset()
+	runHash(context.Background(), []string{"-progress"}, strings.NewReader("abc"), &out, &errOut)
+	if s := errOut.String(); strings.Contains(s, "%") || !strings.Contains(s, "MB/s") {
+		t.Errorf("runHash -progress of stdin wrote %q", s)
+	}
+}
+
+func TestClock(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                                     "0:00",
+		59*time.Second + 600*time.Millisecond: "1:00",
+		61 * time.Minute:                      "1:01:00",
+	} {
+		if got := clock(d); got != want {
+			t.Errorf("clock(%v) => %q, want %q", d, got, want)
+		}
+	}
+	if got := rate(100, 0); got != 0 {
+		t.Errorf("rate(100, 0) => %v, want 0", got)
+	}
+	if got := rate(3e6, 2*time.Second); got != 1.5e6 {
+		t.Errorf("rate(3e6, 2s) => %v, want 1.5e6", got)
+	}
+}
+
+func TestHashCancel(t *testing.T) {
+	dir := tree(t)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var out, errOut bytes.Buffer
+	if rc := runHash(ctx, []string{"-r", dir}, nil, &out, &errOut); rc != 130 {
+		t.Errorf("runHash with a cancelled context returned %d, want 130", rc)
+	}
+	if out.Len() != 0 || !strings.HasSuffix(errOut.String(), "gosha256: interrupted\n") {
+		t.Errorf("runHash with a cancelled context wrote %q, %q", out.String(), errOut.String())
+	}
+
+	// a file cancelled part way through is an error naming the file
+	ctx, cancel = context.WithCancel(context.Background())
+	r := hashSHA256(ctx, "big", readFunc(func(b []byte) (int, error) {
+		cancel()
+		return len(b), nil
+	}), nil)
+	if r.err == nil || r.err.Error() != "big: context canceled" {
+		t.Errorf("hashSHA256 cancelled => %v, want big: context canceled", r.err)
+	}
+}
+
+type readFunc func([]byte) (int, error)
+
+func (f readFunc) Read(b []byte) (int, error) { return f(b) }
+
+func TestHashCache(t *testing.T) {
+	dir := tree(t)
+	defer os.RemoveAll(dir)
+	cache := filepath.Join(dir, "cache")
+	file := filepath.Join(dir, "b")
+
+	run := func(args ...string) (int, string, string) {
+		var out, errOut bytes.Buffer
+		rc := runHash(context.Background(), append([]string{"-cache", cache}, args...), nil, &out, &errOut)
+		return rc, out.String(), errOut.String()
+	}
+	want := fmt.Sprintf("%x  %s\n", sha256.Sum256([]byte("bee")), file)
+	for i := 0; i < 2; i++ {
+		if rc, out, errOut := run(file); rc != 0 || out != want {
+			t.Errorf("runHash -cache run %d => %d %q %q, want %q", i, rc, out, errOut, want)
+		}
+	}
+	c, err := filecache.Open(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, _ := os.Stat(file)
+	key, ok := filecache.KeyOf(fi)
+	if !ok {
+		c.Close()
+		t.Skip("no file keys on this platform")
+	}
+	if _, ok := c.Get(key); !ok || c.Len() != 1 {
+		t.Errorf("cache holds %d entries, not the file hashed", c.Len())
+	}
+
+	// a wrong entry is used, unless verifying, which reports and fixes it
+	c.Put(key, sha256.Sum256([]byte("wrong")))
+	c.Close()
+	wrong := fmt.Sprintf("%x  %s\n", sha256.Sum256([]byte("wrong")), file)
+	if _, out, _ := run(file); out != wrong {
+		t.Errorf("runHash -cache with a planted entry => %q, want %q", out, wrong)
+	}
+	if rc, _, _ := run("-verify-cache", "-verify-fraction", "0", file); rc != 0 {
+		t.Errorf("runHash -verify-cache of none of the entries => %d", rc)
+	}
+	if rc, _, errOut := run("-verify-cache", "-verify-fraction", "1", file); rc != 1 || !strings.Contains(errOut, "cached digest") {
+		t.Errorf("runHash -verify-cache with a wrong entry => %d %q", rc, errOut)
+	}
+	if rc, out, _ := run(file); rc != 0 || out != want {
+		t.Errorf("runHash -cache after -verify-cache => %d %q, want %q", rc, out, want)
+	}
+
+	// a changed file is hashed again
+	ioutil.WriteFile(file, []byte("bees"), 0644)
+	if _, out, _ := run(file); out == want {
+		t.Errorf("runHash -cache of a changed file used the cache")
+	}
+}
+
+func TestHashTree(t *testing.T) {
+	dir := tree(t)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "b")
+
+	var out, errOut bytes.Buffer
+	if rc := runHash(context.Background(), []string{"-tree", "glacier", "-o", "json", file}, nil, &out, &errOut); rc != 0 {
+		t.Fatalf("runHash -tree glacier returned %d: %s", rc, errOut.String())
+	}
+	var r record
+	json.Unmarshal(out.Bytes(), &r)
+	// one chunk: the SHA-256 of the file
+	if r.Algorithm != "sha256-glacier" || r.Digest != fmt.Sprintf("%x", sha256.Sum256([]byte("bee"))) {
+		t.Errorf("runHash -tree glacier => %+v", r)
+	}
+
+	out.Reset()
+	runHash(context.Background(), []string{"-tree", "merkle", file}, nil, &out, &errOut)
+	leaf := sha256.Sum256([]byte("\x00bee"))
+	if want := fmt.Sprintf("%x  %s\n", leaf, file); out.String() != want {
+		t.Errorf("runHash -tree merkle => %q, want %q", out.String(), want)
+	}
+
+	for _, args := range [][]string{{"-tree", "md5"}, {"-tree", "dropbox", "-cache", filepath.Join(dir, "cache")}} {
+		if rc := runHash(context.Background(), append(args, file), nil, &out, &errOut); rc != 2 {
+			t.Errorf("runHash %q returned %d, want 2", args, rc)
+		}
+	}
+}
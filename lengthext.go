@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/jwatson0/go/gosha256/sha2/lengthext"
+)
+
+// runLengthext implements "gosha256 lengthext", forging the SHA-256 of
+// secret || msg || glue || ext from that of secret || msg
+func runLengthext(args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("lengthext", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	digest := fs.String("digest", "", "the known SHA-256 of secret || msg, in `hex`")
+	keyLen := fs.Int("keylen", 0, "the length of the secret in `bytes`")
+	msg := fs.String("msg", "", "the known `message` after the secret")
+	ext := fs.String("ext", "", "the `data` to append")
+	fs.Usage = func() {
+		fmt.Fprintf(errOut, "usage: gosha256 lengthext -digest hex -keylen n [-msg message] -ext data\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	b, err := hex.DecodeString(*digest)
+	if err != nil || len(b) != 32 || *keyLen < 0 || fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	var d [32]byte
+	copy(d[:], b)
+
+	forged, glue := lengthext.Forge(d, uint64(*keyLen+len(*msg)), []byte(*ext))
+	fmt.Fprintf(out, "digest  %x\nglue    %x\nmessage %x\n", forged, glue,
+		lengthext.Extend([]byte(*msg), *keyLen, []byte(*ext)))
+	return 0
+}
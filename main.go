@@ -1,41 +1,185 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/encoding"
+	"github.com/jwatson0/go/gosha256/sha2/filecache"
+	"github.com/jwatson0/go/gosha256/sha2/treehash"
 )
 
-func main() {
-	// read from stdin until EOF
-	var f *os.File = os.Stdin
+const usage = `usage: gosha256 [-r] [-L] [-j n] [-e format] [-o text|json|csv] [-progress]
+                [-cache path [-verify-cache [-verify-fraction f]] | -tree scheme] [file ...]
+       gosha256 debug [-x] [-f file | message]
+       gosha256 serve-viz [-addr address]
+       gosha256 avalanche [flags] [block]
+       gosha256 dirhash [-prefix p] dir|file.zip ...
+       gosha256 daemon [-addr address | -socket path] [-j n] [-cache path]
+       gosha256 mine [-header hex] [-bits hex | -zeros n] [-start nonce] [-j n]
+       gosha256 lengthext -digest hex -keylen n [-msg message] -ext data
+
+With no files, or when file is -, gosha256 hashes standard input.
+Run a command with -h for its flags.
 
-	// create buffer
-	var buf []byte = make([]byte, sha2.Sha256BlocksizeBytes*2048) // large reading buffer
-	var n int
-	var err error
+`
 
-	// TODO set up sha processor with pointer to buf, start, and end indexes
-	// use channel to send message with new end index when new data available
-	// carefully handle buffer wraparound
+func main() {
+	// the first interrupt stops hashing cleanly, a second one kills
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
-	// read and loop until io.EOF
-	n, err = f.Read(buf)
-	for n > 0 { // while we have read any bytes
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "debug":
+			os.Exit(runDebug(os.Args[2:], os.Stdin, os.Stdout))
+		case "serve-viz":
+			os.Exit(runServeViz(os.Args[2:], os.Stderr))
+		case "avalanche":
+			os.Exit(runAvalanche(os.Args[2:], os.Stdout, os.Stderr))
+		case "dirhash":
+			os.Exit(runDirhash(os.Args[2:], os.Stdout, os.Stderr))
+		case "daemon":
+			os.Exit(runDaemon(ctx, os.Args[2:], os.Stderr))
+		case "mine":
+			os.Exit(runMine(ctx, os.Args[2:], os.Stdout, os.Stderr))
+		case "lengthext":
+			os.Exit(runLengthext(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+	os.Exit(runHash(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-		// print any errors
-		if err != nil && err != io.EOF {
-			fmt.Fprintf(os.Stderr, "Read error: %v", err)
+// runHash hashes files, or stdin, printing lines like sha256sum or records,
+// until ctx is cancelled
+func runHash(ctx context.Context, args []string, stdin io.Reader, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("gosha256", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	recursive := fs.Bool("r", false, "hash the files in directories, recursively")
+	follow := fs.Bool("L", false, "follow symbolic links when walking directories")
+	workers := fs.Int("j", runtime.NumCPU(), "hash `n` files at a time")
+	enc := fs.String("e", "hex", "print digests as `format`: "+formatNames())
+	kind := fs.String("o", "text", "output `type`: text, like sha256sum, or json (JSON lines) or csv records")
+	progress := fs.Bool("progress", false, "show progress and throughput on stderr")
+	cache := fs.String("cache", "", "remember digests in the cache file at `path`, skipping unchanged files")
+	verify := fs.Bool("verify-cache", false, "hash some of the files found in the cache anyway and report wrong entries")
+	fraction := fs.Float64("verify-fraction", 0.05, "with -verify-cache, hash a random `fraction` of the files found in the cache")
+	tree := fs.String("tree", "", "hash each file as a tree of chunks, in parallel, with `scheme`: "+schemeNames())
+	fs.Usage = func() {
+		fmt.Fprint(errOut, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *workers < 1 {
+		*workers = 1
+	}
+	format, err := encoding.ParseFormat(*enc)
+	if err != nil {
+		fmt.Fprintf(errOut, "gosha256: %v\n", err)
+		return 2
+	}
+	if *fraction < 0 || *fraction > 1 {
+		fmt.Fprintf(errOut, "gosha256: -verify-fraction must be between 0 and 1\n")
+		return 2
+	}
+	algorithm := "sha256"
+	h := &hasher{stdin: stdin}
+	if *verify {
+		h.verify = *fraction
+	}
+	fileWorkers := *workers
+	if *tree != "" {
+		h.tree = treehash.Lookup(*tree)
+		if h.tree == nil {
+			fmt.Fprintf(errOut, "gosha256: unknown tree scheme %q, want %s\n", *tree, schemeNames())
+			return 2
 		}
-		if err == io.EOF {
-			break
+		if *cache != "" {
+			fmt.Fprintf(errOut, "gosha256: -cache holds SHA-256 digests and can't be used with -tree\n")
+			return 2
+		}
+		// one file at a time, its chunks in parallel
+		algorithm = "sha256-" + h.tree.Name
+		h.treeWorkers = *workers
+		fileWorkers = 1
+	}
+	o, err := newOutput(*kind, out, errOut, format, algorithm)
+	if err != nil {
+		fmt.Fprintf(errOut, "gosha256: %v\n", err)
+		return 2
+	}
+
+	status := 0
+	write := func(r result) {
+		if r.err != nil {
+			status = 1
 		}
-		// FIXME for now, bail after one pass
-		fmt.Fprintf(os.Stderr, "Warning: Data left on input queue. Read %d bytes but no EOF", n)
-		// n, err = f.Read(buf[n: ??? ])
-		break
+		o.write(r)
 	}
 
+	if *cache != "" {
+		c, err := filecache.Open(*cache)
+		if err != nil {
+			fmt.Fprintf(errOut, "gosha256: %v\n", err)
+			return 1
+		}
+		defer c.Close()
+		h.cache = c
+	}
+
+	files := []string{"-"}
+	if fs.NArg() > 0 {
+		files = collect(fs.Args(), *recursive, *follow, func(path string, err error) {
+			write(result{path: path, err: err})
+		})
+	}
+	var bar *progressBar
+	if *progress {
+		bar = &progressBar{w: errOut, total: totalSize(files), start: time.Now()}
+		h.progress = sha2.NewProgress(progressInterval, bar.draw)
+	}
+	h.hashFiles(ctx, files, fileWorkers, write)
+	if h.progress != nil {
+		bar.finish(h.progress.Stop())
+	}
+
+	if err := o.flush(); err != nil {
+		fmt.Fprintf(errOut, "gosha256: %v\n", err)
+		return 1
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(errOut, "gosha256: interrupted")
+		return 130
+	}
+	return status
+}
+
+func schemeNames() string {
+	var names []string
+	for _, s := range treehash.Schemes {
+		names = append(names, s.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func formatNames() string {
+	var names []string
+	for _, f := range encoding.Formats() {
+		names = append(names, f.String())
+	}
+	return strings.Join(names, ", ")
 }
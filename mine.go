@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+
+	"github.com/jwatson0/go/gosha256/sha2/pow"
+)
+
+// the Bitcoin genesis block header, nonce 0x7c2bac1d
+const genesisHeader = "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c"
+
+// runMine implements "gosha256 mine", searching for a nonce in a block
+// header until ctx is cancelled
+func runMine(ctx context.Context, args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("mine", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	header := fs.String("header", genesisHeader, "the 80-byte block header, in `hex`")
+	offset := fs.Int("offset", pow.NonceOffset, "the `offset` of the 4-byte little-endian nonce in the header")
+	bits := fs.String("bits", "", "the target in compact `hex` form, e.g. 1d00ffff, instead of -zeros")
+	zeros := fs.Int("zeros", 20, "the target: `n` leading zero bits")
+	start := fs.Uint64("start", 0, "the first `nonce` to try")
+	count := fs.Uint64("count", 0, "try `n` nonces, or up to the last if 0")
+	workers := fs.Int("j", runtime.NumCPU(), "search with `n` goroutines")
+	quiet := fs.Bool("q", false, "don't report the hash rate every second")
+	fs.Usage = func() {
+		fmt.Fprintf(errOut, "usage: gosha256 mine [-header hex] [-offset n] [-bits hex | -zeros n] [-start nonce] [-count n] [-j n] [-q]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	h, err := hex.DecodeString(*header)
+	if err != nil || len(h) != pow.HeaderSize {
+		fmt.Fprintf(errOut, "gosha256: -header must be %d bytes of hex\n", pow.HeaderSize)
+		return 2
+	}
+	if *offset < 0 || *offset+4 > pow.HeaderSize {
+		fmt.Fprintf(errOut, "gosha256: -offset must be from 0 to %d\n", pow.HeaderSize-4)
+		return 2
+	}
+	if *start > 0xffffffff {
+		fmt.Fprintf(errOut, "gosha256: -start must be a 32-bit nonce\n")
+		return 2
+	}
+	job := pow.Job{Header: h, NonceOffset: *offset, Workers: *workers, Start: uint32(*start), Count: *count}
+	if *bits != "" {
+		b, err := strconv.ParseUint(*bits, 16, 32)
+		if err != nil {
+			fmt.Fprintf(errOut, "gosha256: -bits: %v\n", err)
+			return 2
+		}
+		job.Target = pow.TargetFromBits(uint32(b))
+	} else {
+		if *zeros < 0 || *zeros > 256 {
+			fmt.Fprintf(errOut, "gosha256: -zeros must be from 0 to 256\n")
+			return 2
+		}
+		job.Target = pow.TargetFromZeros(*zeros)
+	}
+	if !*quiet {
+		job.Report = func(s pow.Stats) {
+			fmt.Fprintf(errOut, "%d hashes, %.0f kH/s\n", s.Hashes, s.Rate()/1e3)
+		}
+	}
+
+	r, err := pow.Search(ctx, job)
+	if err == context.Canceled {
+		fmt.Fprintf(errOut, "gosha256: interrupted after %d hashes\n", r.Hashes)
+		return 130
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "gosha256: %v after %d hashes\n", err, r.Hashes)
+		return 1
+	}
+	// shown byte-reversed, as Bitcoin does
+	for i, j := 0, len(r.Hash)-1; i < j; i, j = i+1, j-1 {
+		r.Hash[i], r.Hash[j] = r.Hash[j], r.Hash[i]
+	}
+	fmt.Fprintf(out, "nonce  0x%08x\nhash   %x\nheader %x\n", r.Nonce, r.Hash, r.Header)
+	fmt.Fprintf(errOut, "%d hashes in %v, %.0f kH/s\n", r.Hashes, r.Elapsed.Round(1e6), r.Rate()/1e3)
+	return 0
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwatson0/go/gosha256/sha2/encoding"
+)
+
+// output writes hash results
+type output interface {
+	write(r result)
+	flush() error
+}
+
+// newOutput returns the output for the -o flag, records naming the algorithm
+func newOutput(kind string, out, errOut io.Writer, format encoding.Format, algorithm string) (output, error) {
+	switch kind {
+	case "text":
+		return &textOutput{out: out, errOut: errOut, format: format}, nil
+	case "json":
+		return &jsonOutput{enc: json.NewEncoder(out), format: format, algorithm: algorithm}, nil
+	case "csv":
+		o := &csvOutput{w: csv.NewWriter(out), format: format, algorithm: algorithm}
+		o.w.Write(csvHeader)
+		return o, nil
+	}
+	return nil, fmt.Errorf("unknown output type %q, want text, json or csv", kind)
+}
+
+// textOutput prints lines the way sha256sum does, and errors to errOut
+type textOutput struct {
+	out, errOut io.Writer
+	format      encoding.Format
+}
+
+func (o *textOutput) write(r result) {
+	if r.err != nil {
+		fmt.Fprintf(o.errOut, "gosha256: %v\n", r.err)
+		return
+	}
+	// escape a name holding a backslash or newline and mark the line with
+	// a leading backslash
+	name := r.path
+	prefix := ""
+	if strings.ContainsAny(name, "\\\n") {
+		prefix = "\\"
+		name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+	}
+	fmt.Fprintf(o.out, "%s%s  %s\n", prefix, encoding.Encode(o.format, r.digest), name)
+}
+
+func (o *textOutput) flush() error {
+	return nil
+}
+
+// record is one result as JSON or CSV; errors leave the other fields empty
+type record struct {
+	Path      string  `json:"path"`
+	Size      int64   `json:"size"`
+	Mtime     string  `json:"mtime,omitempty"`
+	Digest    string  `json:"digest,omitempty"`
+	Algorithm string  `json:"algorithm"`
+	Elapsed   float64 `json:"elapsed"` // seconds
+	Error     string  `json:"error,omitempty"`
+}
+
+func newRecord(r result, format encoding.Format, algorithm string) record {
+	rec := record{Path: r.path, Algorithm: algorithm}
+	if r.err != nil {
+		rec.Error = r.err.Error()
+		return rec
+	}
+	rec.Size = r.size
+	if !r.modTime.IsZero() {
+		rec.Mtime = r.modTime.UTC().Format(time.RFC3339Nano)
+	}
+	rec.Digest = encoding.Encode(format, r.digest)
+	rec.Elapsed = r.elapsed.Seconds()
+	return rec
+}
+
+// jsonOutput writes one JSON object per line
+type jsonOutput struct {
+	enc       *json.Encoder
+	format    encoding.Format
+	algorithm string
+	err       error
+}
+
+func (o *jsonOutput) write(r result) {
+	if err := o.enc.Encode(newRecord(r, o.format, o.algorithm)); err != nil && o.err == nil {
+		o.err = err
+	}
+}
+
+func (o *jsonOutput) flush() error {
+	return o.err
+}
+
+var csvHeader = []string{"path", "size", "mtime", "digest", "algorithm", "elapsed", "error"}
+
+// csvOutput writes CSV records after a header row
+type csvOutput struct {
+	w         *csv.Writer
+	format    encoding.Format
+	algorithm string
+}
+
+func (o *csvOutput) write(r result) {
+	rec := newRecord(r, o.format, o.algorithm)
+	size, elapsed := "", ""
+	if rec.Error == "" {
+		size = strconv.FormatInt(rec.Size, 10)
+		elapsed = strconv.FormatFloat(rec.Elapsed, 'f', -1, 64)
+	}
+	o.w.Write([]string{rec.Path, size, rec.Mtime, rec.Digest, rec.Algorithm, elapsed, rec.Error})
+}
+
+func (o *csvOutput) flush() error {
+	o.w.Flush()
+	return o.w.Error()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// progressInterval is how often the -progress bar is redrawn
+const progressInterval = 200 * time.Millisecond
+
+// progressBar draws the -progress bar, for total bytes or, if total is
+// negative, just the count and rate
+type progressBar struct {
+	w     io.Writer
+	total int64
+	start time.Time
+}
+
+const barWidth = 30
+
+// rate returns n bytes over d in bytes per second, or 0 if no time has
+// passed, as for an empty or cached input
+func rate(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+func (b *progressBar) draw(n int64) {
+	rate := rate(n, time.Since(b.start))
+	if b.total < 0 {
+		fmt.Fprintf(b.w, "\r%s  %s/s   ", megabytes(float64(n)), megabytes(rate))
+		return
+	}
+	frac := 1.0
+	if b.total > 0 {
+		frac = float64(n) / float64(b.total)
+	}
+	if frac > 1 {
+		frac = 1
+	}
+	fill := int(frac * barWidth)
+	bar := strings.Repeat("=", fill)
+	if fill < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-fill-1)
+	}
+	eta := "--:--"
+	if rate > 0 {
+		eta = clock(time.Duration(float64(b.total-n) / rate * float64(time.Second)))
+	}
+	fmt.Fprintf(b.w, "\r[%s] %3.0f%%  %s  %s/s  ETA %s   ", bar, frac*100, megabytes(float64(n)), megabytes(rate), eta)
+}
+
+// finish prints the throughput summary after the last draw
+func (b *progressBar) finish(n int64) {
+	d := time.Since(b.start)
+	fmt.Fprintf(b.w, "\nhashed %s in %v, %s/s\n", megabytes(float64(n)), d.Round(time.Millisecond), megabytes(rate(n, d)))
+}
+
+func megabytes(n float64) string {
+	return fmt.Sprintf("%.1f MB", n/1e6)
+}
+
+// clock formats d as m:ss or h:mm:ss
+func clock(d time.Duration) string {
+	s := int64(d.Round(time.Second) / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// totalSize returns the total size of files, or -1 if any is not a regular
+// file, such as stdin, whose size isn't known in advance
+func totalSize(files []string) int64 {
+	var total int64
+	for _, f := range files {
+		if f == "-" {
+			return -1
+		}
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue // reported when it is hashed
+		}
+		if !fi.Mode().IsRegular() {
+			return -1
+		}
+		total += fi.Size()
+	}
+	return total
+}
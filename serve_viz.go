@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jwatson0/go/gosha256/sha2/viz"
+)
+
+// runServeViz implements "gosha256 serve-viz"
+func runServeViz(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("serve-viz", flag.ContinueOnError)
+	fs.SetOutput(out)
+	addr := fs.String("addr", "localhost:8256", "listen on `address`")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	fmt.Fprintf(out, "serving the SHA-256 visualizer on http://%s/\n", *addr)
+	if err := http.ListenAndServe(*addr, viz.Handler()); err != nil {
+		fmt.Fprintf(out, "serve-viz: %v\n", err)
+		return 1
+	}
+	return 0
+}
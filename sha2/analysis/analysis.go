@@ -0,0 +1,128 @@
+// Package analysis measures how quickly the SHA-256 compression function
+// diffuses a one bit change of its input, round by round.
+//
+// All measurements are taken on a single 512-bit block compressed from the
+// initial hash value H(0), without padding, so that reduced-round versions of
+// the function can be compared directly.
+package analysis
+
+import (
+	"math/bits"
+	"math/rand"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+const (
+	InputBits  = sha2.Sha256BlocksizeBits
+	OutputBits = 256
+)
+
+// states returns the working variables a..h after each of the first rounds rounds.
+func states(block []byte, rounds int) [][8]uint32 {
+	s := make([][8]uint32, 0, rounds)
+	h := sha2.Sha256InitialHash()
+	sha2.Sha256CompressRounds(&h, block, rounds, &sha2.Trace{
+		Round: func(n int, r sha2.Round) {
+			s = append(s, r.State)
+		},
+	})
+	return s
+}
+
+// compress returns the output of the compression function reduced to rounds rounds.
+func compress(block []byte, rounds int) [8]uint32 {
+	h := sha2.Sha256InitialHash()
+	sha2.Sha256CompressRounds(&h, block, rounds, nil)
+	return h
+}
+
+// distance returns the number of bits that differ between x and y.
+func distance(x, y [8]uint32) int {
+	d := 0
+	for i := range x {
+		d += bits.OnesCount32(x[i] ^ y[i])
+	}
+	return d
+}
+
+// flip inverts input bit i of block, counting from the most significant bit of byte 0.
+func flip(block []byte, i int) {
+	block[i/8] ^= 0x80 >> uint(i%8)
+}
+
+// Diffusion flips each of the 512 bits of block in turn and returns the
+// Hamming distance between the internal states after each round:
+// d[i][t] is the number of the 256 state bits that changed after round t
+// when input bit i was flipped.
+func Diffusion(block [64]byte, rounds int) [][]int {
+	base := states(block[:], rounds)
+	d := make([][]int, InputBits)
+	for i := range d {
+		b := block
+		flip(b[:], i)
+		s := states(b[:], rounds)
+		d[i] = make([]int, rounds)
+		for t := range s {
+			d[i][t] = distance(base[t], s[t])
+		}
+	}
+	return d
+}
+
+// MeanDiffusion averages the distances returned by Diffusion over all input
+// bits, giving the mean number of changed state bits after each round.
+func MeanDiffusion(d [][]int) []float64 {
+	if len(d) == 0 {
+		return nil
+	}
+	m := make([]float64, len(d[0]))
+	for _, row := range d {
+		for t, x := range row {
+			m[t] += float64(x)
+		}
+	}
+	for t := range m {
+		m[t] /= float64(len(d))
+	}
+	return m
+}
+
+// SAC estimates the strict avalanche criterion matrix of the compression
+// function reduced to rounds rounds, over samples random blocks from rng.
+// m[i][j] is the fraction of samples in which flipping input bit i flipped
+// output bit j.  A function meeting the criterion has every entry near 0.5.
+func SAC(rounds, samples int, rng *rand.Rand) [][]float64 {
+	counts := make([][]int, InputBits)
+	for i := range counts {
+		counts[i] = make([]int, OutputBits)
+	}
+
+	var block [64]byte
+	for n := 0; n < samples; n++ {
+		rng.Read(block[:])
+		base := compress(block[:], rounds)
+		for i := 0; i < InputBits; i++ {
+			b := block
+			flip(b[:], i)
+			out := compress(b[:], rounds)
+			for w := range out {
+				x := base[w] ^ out[w]
+				for x != 0 {
+					j := bits.LeadingZeros32(x)
+					counts[i][w*32+j]++
+					x &^= 0x80000000 >> uint(j)
+				}
+			}
+		}
+	}
+
+	m := make([][]float64, InputBits)
+	for i := range m {
+		m[i] = make([]float64, OutputBits)
+		for j := range m[i] {
+			m[i][j] = float64(counts[i][j]) / float64(samples)
+		}
+	}
+	return m
+}
@@ -0,0 +1,90 @@
+package analysis_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/jwatson0/go/gosha256/sha2/analysis"
+)
+
+func TestDiffusion(t *testing.T) {
+	var block [64]byte
+	d := analysis.Diffusion(block, 64)
+	if len(d) != 512 || len(d[0]) != 64 {
+		t.Fatalf("Diffusion returned %dx%d, want 512x64", len(d), len(d[0]))
+	}
+	for i := 0; i < 512; i++ {
+		// W[0] enters in round 0 through T1, changing a and e
+		// the other words can't change anything until their round
+		if i < 32 && d[i][0] < 2 {
+			t.Errorf("bit %d: %d state bits changed after round 0, want at least 2", i, d[i][0])
+		}
+		if w := i / 32; w > 0 && d[i][w-1] != 0 {
+			t.Errorf("bit %d: %d state bits changed before round %d, want 0", i, d[i][w-1], w)
+		}
+	}
+	m := analysis.MeanDiffusion(d)
+	if m[63] < 120 || m[63] > 136 {
+		t.Errorf("mean distance after 64 rounds is %.1f, want about 128", m[63])
+	}
+}
+
+func TestSAC(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	mean := func(m [][]float64) float64 {
+		s := 0.0
+		for _, row := range m {
+			for _, x := range row {
+				s += x
+			}
+		}
+		return s / float64(len(m)*len(m[0]))
+	}
+
+	full := analysis.SAC(64, 16, rng)
+	if x := mean(full); x < 0.48 || x > 0.52 {
+		t.Errorf("SAC mean for 64 rounds is %.3f, want about 0.5", x)
+	}
+
+	// after one round only W[0] has been mixed in
+	one := analysis.SAC(1, 4, rng)
+	for i := 32; i < 512; i++ {
+		for j, x := range one[i] {
+			if x != 0 {
+				t.Fatalf("SAC for 1 round: bit %d flipped output bit %d", i, j)
+			}
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	var block [64]byte
+	m := analysis.Floats(analysis.Diffusion(block, 8))
+
+	var buf bytes.Buffer
+	if err := analysis.WriteCSV(&buf, "round", m); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 513 || len(recs[0]) != 9 || recs[0][1] != "round0" {
+		t.Errorf("WriteCSV wrote %d records of %d fields, header %v", len(recs), len(recs[0]), recs[0])
+	}
+
+	buf.Reset()
+	if err := analysis.WritePNG(&buf, m, 256, 2); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 1024 {
+		t.Errorf("WritePNG image is %dx%d, want 16x1024", b.Dx(), b.Dy())
+	}
+}
@@ -0,0 +1,93 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+)
+
+// WriteCSV writes a matrix as CSV, one row per input bit.  The header row
+// names the columns with prefix and their index, e.g. "round0" or "out0".
+func WriteCSV(w io.Writer, prefix string, m [][]float64) error {
+	cw := csv.NewWriter(w)
+	if len(m) > 0 {
+		head := make([]string, len(m[0])+1)
+		head[0] = "bit"
+		for j := range m[0] {
+			head[j+1] = prefix + strconv.Itoa(j)
+		}
+		cw.Write(head)
+	}
+	for i, row := range m {
+		rec := make([]string, len(row)+1)
+		rec[0] = strconv.Itoa(i)
+		for j, x := range row {
+			rec[j+1] = strconv.FormatFloat(x, 'g', -1, 64)
+		}
+		cw.Write(rec)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Floats converts the distances returned by Diffusion for WriteCSV and Heatmap.
+func Floats(d [][]int) [][]float64 {
+	m := make([][]float64, len(d))
+	for i, row := range d {
+		m[i] = make([]float64, len(row))
+		for j, x := range row {
+			m[i][j] = float64(x)
+		}
+	}
+	return m
+}
+
+// Heatmap draws a matrix with one scale x scale pixel square per entry,
+// rows going down and columns across.  Values are scaled from 0 to max:
+// blue at 0, white at max/2 and red at max, so an ideal SAC matrix
+// (max 1) or an ideal diffusion matrix (max 256) comes out white.
+func Heatmap(m [][]float64, max float64, scale int) image.Image {
+	if scale < 1 {
+		scale = 1
+	}
+	cols := 0
+	if len(m) > 0 {
+		cols = len(m[0])
+	}
+	img := image.NewRGBA(image.Rect(0, 0, cols*scale, len(m)*scale))
+	for i, row := range m {
+		for j, x := range row {
+			c := heat(x / max)
+			for y := i * scale; y < (i+1)*scale; y++ {
+				for x := j * scale; x < (j+1)*scale; x++ {
+					img.SetRGBA(x, y, c)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// WritePNG encodes Heatmap(m, max, scale) as a PNG.
+func WritePNG(w io.Writer, m [][]float64, max float64, scale int) error {
+	return png.Encode(w, Heatmap(m, max, scale))
+}
+
+// heat maps 0..1 onto blue, white, red.
+func heat(v float64) color.RGBA {
+	if v < 0 {
+		v = 0
+	}
+	if v > 1 {
+		v = 1
+	}
+	if v < 0.5 {
+		c := uint8(255 * v * 2)
+		return color.RGBA{c, c, 255, 255}
+	}
+	c := uint8(255 * (1 - v) * 2)
+	return color.RGBA{255, c, c, 255}
+}
@@ -0,0 +1,251 @@
+// Package cas is a content-addressed store of blobs on disk, each named by
+// its SHA-256 digest:
+//
+//	dir/objects/ab/cdef…  the blob with digest abcdef…
+//	dir/tmp/              blobs being written
+//
+// A blob is written to a temporary file while it is hashed and renamed into
+// place once complete, so a blob in objects/ is always whole.  Blobs are
+// read back through a reader that checks the digest at the end.
+//
+// Blobs no longer reachable from a set of roots are removed by GC, and the
+// blobs of an OCI image can be exported to an image layout, whose
+// blobs/sha256/ directory holds the same files under their full digests.
+package cas
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/internal/fsutil"
+)
+
+// Digest is the SHA-256 of a blob.
+type Digest [32]byte
+
+// String returns the digest in OCI form, "sha256:" and the hex digest.
+func (d Digest) String() string {
+	return "sha256:" + d.Hex()
+}
+
+// Hex returns the hex digest.
+func (d Digest) Hex() string {
+	return hex.EncodeToString(d[:])
+}
+
+// ParseDigest parses a digest in OCI form or plain hex.
+func ParseDigest(s string) (Digest, error) {
+	var d Digest
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "sha256:"))
+	if err != nil || len(b) != len(d) {
+		return d, errors.New("cas: malformed digest " + s)
+	}
+	copy(d[:], b)
+	return d, nil
+}
+
+// Store is a blob store in a directory.  It is safe for concurrent use, and
+// by several processes.
+type Store struct {
+	dir string
+}
+
+// Open returns the store in dir, creating it if need be.
+func Open(dir string) (*Store, error) {
+	for _, sub := range []string{"objects", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Store{dir: dir}, nil
+}
+
+// path returns the file of the blob with digest d.
+func (s *Store) path(d Digest) string {
+	h := d.Hex()
+	return filepath.Join(s.dir, "objects", h[:2], h[2:])
+}
+
+// Put stores everything read from r and returns its digest.  A blob already
+// in the store is replaced, so putting it again repairs a corrupted copy.
+func (s *Store) Put(r io.Reader) (Digest, error) {
+	f, err := ioutil.TempFile(filepath.Join(s.dir, "tmp"), "put-*")
+	if err != nil {
+		return Digest{}, err
+	}
+	defer os.Remove(f.Name()) // fails once renamed
+	w := sha2.NewHashingWriter(f)
+	_, err = io.Copy(w, r)
+	if err == nil {
+		err = f.Chmod(0444)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Digest{}, err
+	}
+
+	d := Digest(w.Sum256())
+	p := s.path(d)
+	dir := filepath.Dir(p)
+	if err := os.Mkdir(dir, 0755); err == nil {
+		if err := fsutil.SyncDir(dir); err != nil {
+			return Digest{}, err
+		}
+	} else if !os.IsExist(err) {
+		return Digest{}, err
+	}
+	// a rename replaces the blob atomically, except where a read-only file
+	// can't be replaced; the blob there must then be whole
+	if err := os.Rename(f.Name(), p); err != nil {
+		if s.check(d) != nil {
+			return Digest{}, err
+		}
+		return d, nil
+	}
+	return d, fsutil.SyncDir(p)
+}
+
+// check reads the blob with digest d to the end, returning
+// sha2.ErrDigestMismatch if it is corrupt.
+func (s *Store) check(d Digest) error {
+	r, err := s.Get(d)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(ioutil.Discard, r)
+	return err
+}
+
+// blob is a stored blob being read, checked against its digest.
+type blob struct {
+	*sha2.VerifyingReader
+	f *os.File
+}
+
+func (b *blob) Close() error {
+	return b.f.Close()
+}
+
+// Get returns a reader of the blob with digest d.  If the blob has been
+// corrupted on disk, its last read fails with sha2.ErrDigestMismatch
+// instead of io.EOF, so it must be read to the end before being trusted.
+func (s *Store) Get(d Digest) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(d))
+	if err != nil {
+		return nil, err
+	}
+	return &blob{sha2.NewVerifyingReader(f, d), f}, nil
+}
+
+// Has reports whether the blob with digest d is stored.
+func (s *Store) Has(d Digest) bool {
+	_, err := os.Stat(s.path(d))
+	return err == nil
+}
+
+// Delete removes the blob with digest d, if it is stored.
+func (s *Store) Delete(d Digest) error {
+	err := os.Remove(s.path(d))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Walk calls fn with the digest and size of each stored blob, in order of
+// digest, stopping at the first error, which it returns.  Files in objects/
+// that aren't named for a digest are skipped.
+func (s *Store) Walk(fn func(d Digest, size int64) error) error {
+	root := filepath.Join(s.dir, "objects")
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		d, err := ParseDigest(strings.Replace(filepath.ToSlash(rel), "/", "", 1))
+		if err != nil || s.path(d) != path {
+			return nil
+		}
+		return fn(d, fi.Size())
+	})
+}
+
+// GC removes every blob not reachable from roots, refs giving the digests
+// each blob refers to; with refs nil only the roots are kept.  It returns the
+// number of blobs removed.  A blob put during GC, before anything refers to
+// it, may be removed.  GC also removes the temporary files of Puts that
+// didn't finish, once they are a day old.
+func (s *Store) GC(roots []Digest, refs func(Digest) ([]Digest, error)) (int, error) {
+	live := map[Digest]bool{}
+	queue := append([]Digest(nil), roots...)
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		if live[d] || !s.Has(d) {
+			continue
+		}
+		live[d] = true
+		if refs == nil {
+			continue
+		}
+		more, err := refs(d)
+		if err != nil {
+			return 0, err
+		}
+		queue = append(queue, more...)
+	}
+
+	var dead []Digest
+	if err := s.Walk(func(d Digest, _ int64) error {
+		if !live[d] {
+			dead = append(dead, d)
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	for i, d := range dead {
+		if err := s.Delete(d); err != nil {
+			return i, err
+		}
+	}
+	return len(dead), s.removeStale(time.Now().Add(-staleTemp))
+}
+
+// staleTemp is the age of an untouched temporary file that no Put can still
+// be writing.
+const staleTemp = 24 * time.Hour
+
+// removeStale removes the temporary files in tmp/ last written before t.
+func (s *Store) removeStale(t time.Time) error {
+	tmp := filepath.Join(s.dir, "tmp")
+	fis, err := ioutil.ReadDir(tmp)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if !strings.HasPrefix(fi.Name(), "put-") || !fi.ModTime().Before(t) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(tmp, fi.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
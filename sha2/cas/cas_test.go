@@ -0,0 +1,223 @@
+package cas_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/cas"
+)
+
+func store(t *testing.T) (*cas.Store, string) {
+	dir, err := ioutil.TempDir("", "cas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	s, err := cas.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+func put(t *testing.T, s *cas.Store, data string) cas.Digest {
+	d, err := s.Put(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func get(s *cas.Store, d cas.Digest) (string, error) {
+	r, err := s.Get(d)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	return string(b), err
+}
+
+func TestStore(t *testing.T) {
+	s, dir := store(t)
+	d := put(t, s, "hello")
+	if want := cas.Digest(sha2.Sha256([]byte("hello"))); d != want {
+		t.Errorf("Put => %v, want %v", d, want)
+	}
+	h := d.Hex()
+	if _, err := os.Stat(filepath.Join(dir, "objects", h[:2], h[2:])); err != nil {
+		t.Errorf("blob not at objects/%s/%s: %v", h[:2], h[2:], err)
+	}
+	if got, err := get(s, d); got != "hello" || err != nil {
+		t.Errorf("Get => %q, %v", got, err)
+	}
+	if d2 := put(t, s, "hello"); d2 != d {
+		t.Errorf("Put again => %v, want %v", d2, d)
+	}
+	if names, _ := ioutil.ReadDir(filepath.Join(dir, "tmp")); len(names) != 0 {
+		t.Errorf("%d files left in tmp", len(names))
+	}
+
+	other := cas.Digest(sha2.Sha256([]byte("other")))
+	if !s.Has(d) || s.Has(other) {
+		t.Errorf("Has => %v, %v, want true, false", s.Has(d), s.Has(other))
+	}
+	if _, err := s.Get(other); !os.IsNotExist(err) {
+		t.Errorf("Get(missing) => %v", err)
+	}
+
+	p, err := cas.ParseDigest(d.String())
+	if err != nil || p != d || !strings.HasPrefix(d.String(), "sha256:") {
+		t.Errorf("ParseDigest(%v) => %v, %v", d, p, err)
+	}
+	if _, err := cas.ParseDigest("sha256:abc"); err == nil {
+		t.Errorf("ParseDigest(short) => no error")
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	s, dir := store(t)
+	d := put(t, s, "precious")
+	h := d.Hex()
+	p := filepath.Join(dir, "objects", h[:2], h[2:])
+	os.Chmod(p, 0644)
+	if err := ioutil.WriteFile(p, []byte("precioux"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(s, d); err != sha2.ErrDigestMismatch {
+		t.Errorf("Get(corrupt blob) => %v, want ErrDigestMismatch", err)
+	}
+
+	// putting it again repairs it
+	put(t, s, "precious")
+	if got, err := get(s, d); got != "precious" || err != nil {
+		t.Errorf("Get after putting a corrupt blob again => %q, %v", got, err)
+	}
+}
+
+func TestWalkGC(t *testing.T) {
+	s, dir := store(t)
+	var all []string
+	for i := 0; i < 5; i++ {
+		all = append(all, put(t, s, fmt.Sprint("blob ", i)).String())
+	}
+	// not a blob
+	ioutil.WriteFile(filepath.Join(dir, "objects", "README"), []byte("hi"), 0644)
+
+	walk := func() []string {
+		var got []string
+		if err := s.Walk(func(d cas.Digest, size int64) error {
+			if size != 6 {
+				t.Errorf("Walk: %v has size %d, want 6", d, size)
+			}
+			got = append(got, d.String())
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	sort.Strings(all)
+	if got := walk(); strings.Join(got, " ") != strings.Join(all, " ") {
+		t.Errorf("Walk => %q, want %q", got, all)
+	}
+
+	// blob 0 refers to blob 1, which refers to blob 2
+	d := func(i int) cas.Digest { return cas.Digest(sha2.Sha256([]byte(fmt.Sprint("blob ", i)))) }
+	refs := func(x cas.Digest) ([]cas.Digest, error) {
+		for i := 0; i < 2; i++ {
+			if x == d(i) {
+				return []cas.Digest{d(i + 1)}, nil
+			}
+		}
+		return nil, nil
+	}
+	n, err := s.GC([]cas.Digest{d(0)}, refs)
+	if err != nil || n != 2 {
+		t.Errorf("GC => %d, %v, want 2 removed", n, err)
+	}
+	for i := 0; i < 5; i++ {
+		if s.Has(d(i)) != (i < 3) {
+			t.Errorf("after GC, Has(blob %d) => %v", i, s.Has(d(i)))
+		}
+	}
+	if n, _ := s.GC([]cas.Digest{d(2)}, nil); n != 2 || !s.Has(d(2)) {
+		t.Errorf("GC without refs => %d removed", n)
+	}
+
+	// a Put that crashed long ago left its file, one in progress is kept
+	stale, fresh := filepath.Join(dir, "tmp", "put-1"), filepath.Join(dir, "tmp", "put-2")
+	ioutil.WriteFile(stale, []byte("partial"), 0644)
+	ioutil.WriteFile(fresh, []byte("partial"), 0644)
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(stale, old, old)
+	if _, err := s.GC([]cas.Digest{d(2)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("GC left a stale temporary file: %v", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("GC removed a fresh temporary file: %v", err)
+	}
+}
+
+func TestExportOCI(t *testing.T) {
+	s, _ := store(t)
+	layer := put(t, s, "layer tarball")
+	config := put(t, s, `{"architecture":"amd64","os":"linux"}`)
+	manifest := put(t, s, fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",
+		"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%v","size":37},
+		"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"%v","size":13}]}`, config, layer))
+	index := put(t, s, fmt.Sprintf(`{"schemaVersion":2,"manifests":[
+		{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"%v","size":100}]}`, manifest))
+	stray := put(t, s, "not in the image")
+
+	refs, err := s.OCIRefs(manifest)
+	if err != nil || len(refs) != 2 {
+		t.Errorf("OCIRefs(manifest) => %v, %v", refs, err)
+	}
+	if n, err := s.GC([]cas.Digest{index}, s.OCIRefs); n != 1 || err != nil || s.Has(stray) {
+		t.Errorf("GC(index) => %d, %v, want the stray blob removed", n, err)
+	}
+	if refs, err := s.OCIRefs(put(t, s, fmt.Sprintf(" \n{\"digest\":\"%v\"}", layer))); err != nil || len(refs) != 1 || refs[0] != layer {
+		t.Errorf("OCIRefs(JSON after spaces) => %v, %v", refs, err)
+	}
+	if refs, err := s.OCIRefs(layer); err != nil || refs != nil {
+		t.Errorf("OCIRefs(layer) => %v, %v", refs, err)
+	}
+
+	out, err := ioutil.TempDir("", "oci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(out)
+	if err := s.ExportOCI(out, index); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []cas.Digest{manifest, config, layer} {
+		f, err := os.Open(filepath.Join(out, "blobs", "sha256", d.Hex()))
+		if err != nil {
+			t.Errorf("blob %v not exported: %v", d, err)
+			continue
+		}
+		b, _ := ioutil.ReadAll(f)
+		f.Close()
+		if cas.Digest(sha2.Sha256(b)) != d {
+			t.Errorf("exported blob %v has the wrong contents", d)
+		}
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(out, "index.json")); cas.Digest(sha2.Sha256(b)) != index {
+		t.Errorf("index.json isn't the index")
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(out, "oci-layout")); !strings.Contains(string(b), `"imageLayoutVersion":"1.0.0"`) {
+		t.Errorf("oci-layout => %q", b)
+	}
+}
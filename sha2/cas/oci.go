@@ -0,0 +1,147 @@
+package cas
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxManifest is the largest blob OCIRefs parses; layers are larger, and
+// aren't JSON.
+const maxManifest = 4 << 20
+
+// OCIRefs returns the digests an OCI image index, manifest or other JSON
+// blob refers to: every "digest" member holding a sha256 digest, at any
+// depth, such as those of the descriptors of manifests, configs and layers.
+// Other blobs refer to nothing.  It is a refs function for GC.
+func (s *Store) OCIRefs(d Digest) ([]Digest, error) {
+	r, err := s.Get(d)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// a layer is told from JSON by its first byte, without reading it all
+	br := bufio.NewReader(r)
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		if c != '{' {
+			return nil, nil
+		}
+		br.UnreadByte()
+		break
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(br, maxManifest+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxManifest {
+		return nil, nil
+	}
+	var v interface{}
+	if json.Unmarshal(b, &v) != nil {
+		return nil, nil
+	}
+	var refs []Digest
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, x := range v {
+				if s, ok := x.(string); ok && k == "digest" && strings.HasPrefix(s, "sha256:") {
+					if d, err := ParseDigest(s); err == nil {
+						refs = append(refs, d)
+					}
+					continue
+				}
+				walk(x)
+			}
+		case []interface{}:
+			for _, x := range v {
+				walk(x)
+			}
+		}
+	}
+	walk(v)
+	return refs, nil
+}
+
+// ExportOCI writes an OCI image layout at dir: the image index with digest
+// index as index.json, the oci-layout file, and in blobs/sha256/ every blob
+// reachable from the index through OCIRefs.  Blobs are hard-linked where
+// possible, without checking them, and otherwise copied and checked.
+func (s *Store) ExportOCI(dir string, index Digest) error {
+	blobs := filepath.Join(dir, "blobs", "sha256")
+	if err := os.MkdirAll(blobs, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := s.copy(index, filepath.Join(dir, "index.json")); err != nil {
+		return err
+	}
+
+	// the index itself needn't be a blob, but refers to manifests that are
+	refs, err := s.OCIRefs(index)
+	if err != nil {
+		return err
+	}
+	seen := map[Digest]bool{}
+	for len(refs) > 0 {
+		d := refs[0]
+		refs = refs[1:]
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		dst := filepath.Join(blobs, d.Hex())
+		if _, err := os.Stat(dst); os.IsNotExist(err) {
+			if os.Link(s.path(d), dst) != nil {
+				if err := s.copy(d, dst); err != nil {
+					return err
+				}
+			}
+		}
+		more, err := s.OCIRefs(d)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, more...)
+	}
+	return nil
+}
+
+// copy writes the blob with digest d to the file dst, checking its digest.
+func (s *Store) copy(d Digest, dst string) error {
+	r, err := s.Get(d)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
@@ -0,0 +1,190 @@
+// Package chunker cuts a stream into content-defined chunks with FastCDC
+// (Xia et al., USENIX ATC 2016) and hashes each with SHA-256 as it goes, so
+// one pass over the data gives the chunks, their digests and the digest of
+// the whole stream.
+//
+// A cut point depends only on the bytes just before it, through a rolling
+// "gear" hash, so an insertion or deletion changes the chunks around it and
+// no others, and the unchanged chunks deduplicate.  Chunk sizes are
+// normalized: up to the average size a cut needs two more zero bits of the
+// hash than the average would, and after it two fewer, which narrows the
+// spread of sizes around the average.
+package chunker
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// Options are the chunk sizes in bytes.  Avg is rounded down to a power of
+// two; chunks are at least Min bytes, except the last, and at most Max.
+type Options struct {
+	Min, Avg, Max int
+}
+
+// DefaultOptions are those of the FastCDC paper.
+var DefaultOptions = Options{Min: 2 << 10, Avg: 8 << 10, Max: 64 << 10}
+
+var errOptions = errors.New("chunker: need 64 <= Min <= Avg <= Max")
+
+// gear is the table of the rolling hash: 256 random 64-bit values, here
+// taken from the SHA-256 of each byte value so that they are reproducible.
+var gear = func() (g [256]uint64) {
+	for i := range g {
+		d := sha2.New()
+		d.Write([]byte{byte(i)})
+		s := d.Sum256()
+		g[i] = binary.BigEndian.Uint64(s[:])
+	}
+	return g
+}()
+
+// Chunk is a piece of the stream.
+type Chunk struct {
+	Offset int64
+	Length int
+	Digest [32]byte // SHA-256 of Data
+	Data   []byte   // valid until the next call to Next
+}
+
+// Chunker reads chunks from a stream.
+type Chunker struct {
+	r            io.Reader
+	opt          Options
+	maskS, maskL uint64 // for cuts before and after the average size
+	buf          []byte
+	start, end   int // unread data in buf
+	eof          bool
+	err          error
+	off          int64
+	stream       *sha2.Digest
+}
+
+// New returns a Chunker reading from r.
+func New(r io.Reader, opt Options) (*Chunker, error) {
+	if opt.Min < 64 || opt.Min > opt.Avg || opt.Avg > opt.Max {
+		return nil, errOptions
+	}
+	avgBits := bits.Len(uint(opt.Avg)) - 1
+	return &Chunker{
+		r:      r,
+		opt:    opt,
+		maskS:  mask(avgBits + 2),
+		maskL:  mask(avgBits - 2),
+		buf:    make([]byte, 2*opt.Max),
+		stream: sha2.New(),
+	}, nil
+}
+
+// mask returns a mask of the top n bits, which in a gear hash shifted left
+// depend on the last 64 bytes.
+func mask(n int) uint64 {
+	return ^uint64(0) << uint(64-n)
+}
+
+// cut returns the length of the chunk at the start of b.
+func (c *Chunker) cut(b []byte) int {
+	n := len(b)
+	if n <= c.opt.Min {
+		return n
+	}
+	if n > c.opt.Max {
+		n = c.opt.Max
+	}
+	normal := c.opt.Avg
+	if normal > n {
+		normal = n
+	}
+	var fp uint64
+	i := c.opt.Min
+	for ; i < normal; i++ {
+		fp = fp<<1 + gear[b[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = fp<<1 + gear[b[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// fill reads until the buffer holds Max bytes or the stream ends.
+func (c *Chunker) fill() {
+	if c.end-c.start >= c.opt.Max || c.eof {
+		return
+	}
+	copy(c.buf, c.buf[c.start:c.end])
+	c.end -= c.start
+	c.start = 0
+	for c.end < len(c.buf) && !c.eof {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			c.err = err
+			return
+		}
+	}
+}
+
+// Next returns the next chunk, or io.EOF after the last.
+func (c *Chunker) Next() (Chunk, error) {
+	c.fill()
+	if c.err != nil {
+		return Chunk{}, c.err
+	}
+	if c.start == c.end {
+		return Chunk{}, io.EOF
+	}
+	b := c.buf[c.start:c.end]
+	b = b[:c.cut(b)]
+	c.start += len(b)
+
+	// the stream digest is fed in parallel with the chunk's
+	done := make(chan struct{})
+	go func() {
+		c.stream.Write(b)
+		close(done)
+	}()
+	d := sha2.New()
+	d.Write(b)
+	<-done
+	ch := Chunk{Offset: c.off, Length: len(b), Digest: d.Sum256(), Data: b}
+	c.off += int64(len(b))
+	return ch, nil
+}
+
+// Sum256 returns the SHA-256 of the chunks returned so far, which at the
+// end is that of the whole stream.
+func (c *Chunker) Sum256() [32]byte {
+	return c.stream.Sum256()
+}
+
+// Split calls fn with each chunk of r and returns the SHA-256 of r.
+func Split(r io.Reader, opt Options, fn func(Chunk) error) ([32]byte, error) {
+	c, err := New(r, opt)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	for {
+		ch, err := c.Next()
+		if err == io.EOF {
+			return c.Sum256(), nil
+		}
+		if err != nil {
+			return [32]byte{}, err
+		}
+		if err := fn(ch); err != nil {
+			return [32]byte{}, err
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package chunker_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/chunker"
+)
+
+func random(n int, seed int64) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+func chunks(t *testing.T, data []byte, opt chunker.Options) ([]chunker.Chunk, [32]byte) {
+	var cs []chunker.Chunk
+	// a reader returning one byte at a time exercises the buffering
+	sum, err := chunker.Split(iotest.OneByteReader(bytes.NewReader(data)), opt, func(c chunker.Chunk) error {
+		c.Data = append([]byte(nil), c.Data...)
+		cs = append(cs, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cs, sum
+}
+
+func TestChunks(t *testing.T) {
+	data := random(1<<20, 1)
+	opt := chunker.Options{Min: 1 << 10, Avg: 4 << 10, Max: 16 << 10}
+	cs, sum := chunks(t, data, opt)
+
+	if want := sha2.Sha256(data); sum != want {
+		t.Errorf("stream digest %x, want %x", sum, want)
+	}
+	var off int64
+	for i, c := range cs {
+		if c.Offset != off || c.Length != len(c.Data) || !bytes.Equal(c.Data, data[off:off+int64(c.Length)]) {
+			t.Fatalf("chunk %d at %d, %d bytes, doesn't follow on at %d", i, c.Offset, c.Length, off)
+		}
+		if i < len(cs)-1 && (c.Length < opt.Min || c.Length > opt.Max) {
+			t.Errorf("chunk %d is %d bytes, outside [%d, %d]", i, c.Length, opt.Min, opt.Max)
+		}
+		if want := sha2.Sha256(c.Data); c.Digest != want {
+			t.Errorf("chunk %d digest %x, want %x", i, c.Digest, want)
+		}
+		off += int64(c.Length)
+	}
+	if off != int64(len(data)) {
+		t.Errorf("chunks cover %d bytes, want %d", off, len(data))
+	}
+	if avg := len(data) / len(cs); avg < opt.Avg/2 || avg > 2*opt.Avg {
+		t.Errorf("average chunk %d bytes, want about %d", avg, opt.Avg)
+	}
+}
+
+func TestShift(t *testing.T) {
+	// an insertion near the start changes only the chunks around it
+	data := random(1<<20, 2)
+	edited := append(append(append([]byte(nil), data[:5000]...), "inserted"...), data[5000:]...)
+	a, _ := chunks(t, data, chunker.DefaultOptions)
+	b, _ := chunks(t, edited, chunker.DefaultOptions)
+	seen := map[[32]byte]bool{}
+	for _, c := range a {
+		seen[c.Digest] = true
+	}
+	shared := 0
+	for _, c := range b {
+		if seen[c.Digest] {
+			shared++
+		}
+	}
+	if shared < len(a)-2 {
+		t.Errorf("%d of %d chunks survive an insertion", shared, len(a))
+	}
+}
+
+func TestEdges(t *testing.T) {
+	cs, sum := chunks(t, nil, chunker.DefaultOptions)
+	if len(cs) != 0 || sum != sha2.Sha256(nil) {
+		t.Errorf("empty stream => %d chunks, %x", len(cs), sum)
+	}
+	// zeros never match the gear hash, so are cut at Max
+	cs, _ = chunks(t, make([]byte, 200<<10), chunker.DefaultOptions)
+	if len(cs) != 4 || cs[0].Length != chunker.DefaultOptions.Max || cs[3].Length != 8<<10 {
+		t.Errorf("200 KiB of zeros => %d chunks", len(cs))
+	}
+	if _, err := chunker.New(nil, chunker.Options{Min: 100, Avg: 50, Max: 200}); err == nil {
+		t.Errorf("New(Min > Avg) => no error")
+	}
+
+	boom := errors.New("boom")
+	_, err := chunker.Split(iotest.TimeoutReader(bytes.NewReader(random(300<<10, 3))), chunker.DefaultOptions, func(chunker.Chunk) error { return nil })
+	if err != iotest.ErrTimeout {
+		t.Errorf("Split(failing reader) => %v, want %v", err, iotest.ErrTimeout)
+	}
+	_, err = chunker.Split(bytes.NewReader(random(300<<10, 3)), chunker.DefaultOptions, func(chunker.Chunk) error { return boom })
+	if err != boom {
+		t.Errorf("Split(failing callback) => %v, want %v", err, boom)
+	}
+}
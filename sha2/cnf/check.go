@@ -0,0 +1,53 @@
+package cnf
+
+import (
+	"fmt"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// MaxCheckBits bounds the free bits CrossCheck will enumerate.
+const MaxCheckBits = 20
+
+// CrossCheck verifies the encoding by brute force.  It encodes the function
+// reduced to rounds rounds, fixes the output to the real output for block,
+// and fixes every message bit except those in free.  Then, for each of the
+// 2^len(free) values of the free bits, it checks that the formula is
+// satisfied exactly when sha2.Sha256CompressRounds gives the same output.
+// The gate variables are determined by the message, so evaluating them
+// decides satisfiability without a solver.
+func CrossCheck(h [8]uint32, rounds int, block [64]byte, free []int) error {
+	if len(free) > MaxCheckBits {
+		return fmt.Errorf("cnf: %d free bits is too many to enumerate", len(free))
+	}
+
+	want := h
+	sha2.Sha256CompressRounds(&want, block[:], rounds, nil)
+
+	c := Encode(h, rounds)
+	c.FixMessage(block[:], free)
+	c.FixOutput(want, nil)
+
+	for a := 0; a < 1<<uint(len(free)); a++ {
+		b := block
+		for k, i := range free {
+			if a>>uint(k)&1 == 1 {
+				b[i/8] |= 0x80 >> uint(i%8)
+			} else {
+				b[i/8] &^= 0x80 >> uint(i%8)
+			}
+		}
+
+		got := h
+		sha2.Sha256CompressRounds(&got, b[:], rounds, nil)
+
+		assign := c.Assignment(b[:])
+		if out := c.OutputOf(assign); out != got {
+			return fmt.Errorf("cnf: %d rounds, message %x: encoding gives %8.8x, compression gives %8.8x", rounds, b, out, got)
+		}
+		if sat := c.Satisfies(assign); sat != (got == want) {
+			return fmt.Errorf("cnf: %d rounds, message %x: formula satisfied %v, output matches %v", rounds, b, sat, got == want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Package cnf encodes the SHA-256 compression function as a boolean formula
+// in conjunctive normal form, written in the DIMACS format read by SAT solvers.
+//
+// Every gate is defined by a truth table and encoded with the Tseitin
+// transformation, so the value of each gate variable is fixed by its inputs.
+// Gates whose inputs are constants are folded away.
+package cnf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Lit is a DIMACS literal: variable v is v, its negation is -v.
+type Lit int
+
+// Variable 1 is always true, and used for constants.
+const (
+	True  Lit = 1
+	False Lit = -1
+)
+
+// Not returns the negation of l.
+func (l Lit) Not() Lit {
+	return -l
+}
+
+// Var returns the variable of l.
+func (l Lit) Var() int {
+	if l < 0 {
+		return int(-l)
+	}
+	return int(l)
+}
+
+// maxGateInputs bounds the truth tables kept for each gate.
+const maxGateInputs = 5
+
+type gate struct {
+	out   Lit
+	in    []Lit
+	table uint32 // bit a is the output for input assignment a, input j is bit j of a
+}
+
+// Formula is a set of clauses built from gates.
+type Formula struct {
+	vars    int
+	clauses [][]Lit
+	gates   []gate
+}
+
+// NewFormula returns a formula holding only the constant True.
+func NewFormula() *Formula {
+	f := &Formula{vars: 1}
+	f.AddClause(True)
+	return f
+}
+
+// NewVar allocates a free variable.
+func (f *Formula) NewVar() Lit {
+	f.vars++
+	return Lit(f.vars)
+}
+
+// AddClause adds the disjunction of lits.
+func (f *Formula) AddClause(lits ...Lit) {
+	f.clauses = append(f.clauses, append([]Lit(nil), lits...))
+}
+
+// Vars returns the number of variables.
+func (f *Formula) Vars() int {
+	return f.vars
+}
+
+// Clauses returns the number of clauses.
+func (f *Formula) Clauses() int {
+	return len(f.clauses)
+}
+
+// Gate returns a literal equal to fn applied to the inputs.  Constant inputs
+// are substituted first; if the result is then constant, or equal to one of
+// the inputs or its negation, no variable is allocated.
+func (f *Formula) Gate(fn func(in []bool) bool, in ...Lit) Lit {
+	vals := make([]bool, len(in))
+	var live []Lit
+	var pos []int
+	for i, l := range in {
+		switch l {
+		case True:
+			vals[i] = true
+		case False:
+			vals[i] = false
+		default:
+			live = append(live, l)
+			pos = append(pos, i)
+		}
+	}
+	k := len(live)
+	if k > maxGateInputs {
+		panic("cnf: too many gate inputs")
+	}
+	n := 1 << uint(k)
+
+	var table uint32
+	for a := 0; a < n; a++ {
+		for j, i := range pos {
+			vals[i] = a>>uint(j)&1 == 1
+		}
+		if fn(vals) {
+			table |= 1 << uint(a)
+		}
+	}
+
+	all := uint32(1)<<uint(n) - 1
+	switch table {
+	case 0:
+		return False
+	case all:
+		return True
+	}
+	for j, l := range live {
+		same, inverse := true, true
+		for a := 0; a < n; a++ {
+			if (table>>uint(a)&1 == 1) == (a>>uint(j)&1 == 1) {
+				inverse = false
+			} else {
+				same = false
+			}
+		}
+		if same {
+			return l
+		}
+		if inverse {
+			return -l
+		}
+	}
+
+	// one clause for each row of the truth table:
+	// (inputs != row) or (out == table[row])
+	out := f.NewVar()
+	for a := 0; a < n; a++ {
+		c := make([]Lit, 0, k+1)
+		for j, l := range live {
+			if a>>uint(j)&1 == 1 {
+				c = append(c, -l)
+			} else {
+				c = append(c, l)
+			}
+		}
+		if table>>uint(a)&1 == 1 {
+			c = append(c, out)
+		} else {
+			c = append(c, -out)
+		}
+		f.AddClause(c...)
+	}
+	f.gates = append(f.gates, gate{out: out, in: live, table: table})
+	return out
+}
+
+// Value returns the value of l in an assignment indexed by variable.
+func Value(assign []bool, l Lit) bool {
+	if l < 0 {
+		return !assign[-l]
+	}
+	return assign[l]
+}
+
+// Eval fills in the gate variables of assign, indexed by variable, from the
+// free variables already set in it.  Variable 1 is set to true.
+func (f *Formula) Eval(assign []bool) {
+	assign[True] = true
+	for _, g := range f.gates {
+		a := 0
+		for j, l := range g.in {
+			if Value(assign, l) {
+				a |= 1 << uint(j)
+			}
+		}
+		assign[g.out] = g.table>>uint(a)&1 == 1
+	}
+}
+
+// Satisfies reports whether assign, indexed by variable, satisfies every clause.
+func (f *Formula) Satisfies(assign []bool) bool {
+	for _, c := range f.clauses {
+		ok := false
+		for _, l := range c {
+			if Value(assign, l) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteDIMACS writes the formula in DIMACS CNF format, with each of comments
+// as a "c" line before the problem line.
+func (f *Formula) WriteDIMACS(w io.Writer, comments ...string) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range comments {
+		fmt.Fprintf(bw, "c %s\n", c)
+	}
+	fmt.Fprintf(bw, "p cnf %d %d\n", f.vars, len(f.clauses))
+	for _, c := range f.clauses {
+		for _, l := range c {
+			fmt.Fprintf(bw, "%d ", l)
+		}
+		bw.WriteString("0\n")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,94 @@
+package cnf_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/cnf"
+)
+
+func TestGate(t *testing.T) {
+	f := cnf.NewFormula()
+	x := f.NewVar()
+	and := func(in []bool) bool { return in[0] && in[1] }
+
+	if g := f.Gate(and, x, cnf.True); g != x {
+		t.Errorf("x and true => %d, want %d", g, x)
+	}
+	if g := f.Gate(and, x, cnf.False); g != cnf.False {
+		t.Errorf("x and false => %d, want false", g)
+	}
+	if g := f.Xor(x, cnf.True); g != -x {
+		t.Errorf("x xor true => %d, want %d", g, -x)
+	}
+	if f.Vars() != 2 || f.Clauses() != 1 {
+		t.Errorf("folded gates allocated %d variables and %d clauses", f.Vars(), f.Clauses())
+	}
+}
+
+func TestCrossCheck(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, rounds := range []int{0, 1, 2, 3, 4, 17} {
+		for n := 0; n < 3; n++ {
+			var block [64]byte
+			rng.Read(block[:])
+			free := rng.Perm(512)[:10]
+			if n == 0 {
+				// bits of W[0], which every round uses
+				free = []int{0, 1, 7, 15, 16, 23, 24, 29, 30, 31}
+			}
+			if err := cnf.CrossCheck(sha2.Sha256InitialHash(), rounds, block, free); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
+func TestFullRounds(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	c := cnf.Encode(sha2.Sha256InitialHash(), 64)
+	for n := 0; n < 4; n++ {
+		var block [64]byte
+		rng.Read(block[:])
+		want := sha2.Sha256InitialHash()
+		sha2.Sha256Compress(&want, block[:])
+		if got := c.OutputOf(c.Assignment(block[:])); got != want {
+			t.Errorf("64 round encoding of %x => %8.8x, want %8.8x", block, got, want)
+		}
+	}
+}
+
+func TestWriteDIMACS(t *testing.T) {
+	c := cnf.Encode(sha2.Sha256InitialHash(), 2)
+	var block [64]byte
+	c.FixMessage(block[:], []int{3, 4})
+	var buf bytes.Buffer
+	if err := c.WriteDIMACS(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	s := bufio.NewScanner(&buf)
+	header := ""
+	clauses := 0
+	for s.Scan() {
+		l := s.Text()
+		switch {
+		case strings.HasPrefix(l, "c "):
+		case strings.HasPrefix(l, "p "):
+			header = l
+		default:
+			if !strings.HasSuffix(l, " 0") {
+				t.Fatalf("clause %q does not end in 0", l)
+			}
+			clauses++
+		}
+	}
+	if want := fmt.Sprintf("p cnf %d %d", c.Vars(), c.Clauses()); header != want || clauses != c.Clauses() {
+		t.Errorf("WriteDIMACS header %q with %d clauses, want %q", header, clauses, want)
+	}
+}
@@ -0,0 +1,296 @@
+package cnf
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// Word is a 32-bit word of literals, bit i having weight 2^i.
+type Word [32]Lit
+
+// Const returns the literals of the constant x.
+func Const(x uint32) Word {
+	var w Word
+	for i := range w {
+		if x>>uint(i)&1 == 1 {
+			w[i] = True
+		} else {
+			w[i] = False
+		}
+	}
+	return w
+}
+
+// The gates are derived from the functions in the sha2 package: the bitwise
+// ones by their truth table on a single bit, the σ and Σ functions, which
+// are linear over GF(2), from their value on each single-bit input.
+
+func bitwise(fn func(x, y, z uint32) uint32) func(in []bool) bool {
+	b := func(v bool) uint32 {
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return func(in []bool) bool {
+		return fn(b(in[0]), b(in[1]), b(in[2]))&1 == 1
+	}
+}
+
+var (
+	chGate  = bitwise(sha2.Ch)
+	majGate = bitwise(sha2.Maj)
+)
+
+func parity(in []bool) bool {
+	p := false
+	for _, v := range in {
+		p = p != v
+	}
+	return p
+}
+
+// linear holds, for each output bit of a linear function, the input bits XORed into it.
+type linear [32][]int
+
+func linearOf(fn func(uint32) uint32) linear {
+	var m linear
+	for i := uint(0); i < 32; i++ {
+		y := fn(1 << i)
+		for j := uint(0); j < 32; j++ {
+			if y>>j&1 == 1 {
+				m[j] = append(m[j], int(i))
+			}
+		}
+	}
+	return m
+}
+
+var (
+	upperSigma0 = linearOf(sha2.UpperSigma0)
+	upperSigma1 = linearOf(sha2.UpperSigma1)
+	lowerSigma0 = linearOf(sha2.LowerSigma0)
+	lowerSigma1 = linearOf(sha2.LowerSigma1)
+)
+
+// Xor returns the XOR of lits, in gates of up to three inputs.
+func (f *Formula) Xor(lits ...Lit) Lit {
+	if len(lits) == 0 {
+		return False
+	}
+	for len(lits) > 3 {
+		lits = append([]Lit{f.Gate(parity, lits[:3]...)}, lits[3:]...)
+	}
+	return f.Gate(parity, lits...)
+}
+
+func (f *Formula) apply(m linear, x Word) Word {
+	var w Word
+	for j, taps := range m {
+		in := make([]Lit, len(taps))
+		for k, i := range taps {
+			in[k] = x[i]
+		}
+		w[j] = f.Xor(in...)
+	}
+	return w
+}
+
+// UpperSigma0 returns Σ0(x).
+func (f *Formula) UpperSigma0(x Word) Word { return f.apply(upperSigma0, x) }
+
+// UpperSigma1 returns Σ1(x).
+func (f *Formula) UpperSigma1(x Word) Word { return f.apply(upperSigma1, x) }
+
+// LowerSigma0 returns σ0(x).
+func (f *Formula) LowerSigma0(x Word) Word { return f.apply(lowerSigma0, x) }
+
+// LowerSigma1 returns σ1(x).
+func (f *Formula) LowerSigma1(x Word) Word { return f.apply(lowerSigma1, x) }
+
+// Ch returns Ch(x, y, z).
+func (f *Formula) Ch(x, y, z Word) Word {
+	var w Word
+	for i := range w {
+		w[i] = f.Gate(chGate, x[i], y[i], z[i])
+	}
+	return w
+}
+
+// Maj returns Maj(x, y, z).
+func (f *Formula) Maj(x, y, z Word) Word {
+	var w Word
+	for i := range w {
+		w[i] = f.Gate(majGate, x[i], y[i], z[i])
+	}
+	return w
+}
+
+// Add returns the sum of the words modulo 2^32, as a chain of ripple-carry
+// adders: each sum bit is the parity and each carry the majority of the
+// two input bits and the carry in.
+func (f *Formula) Add(words ...Word) Word {
+	sum := words[0]
+	for _, y := range words[1:] {
+		x := sum
+		c := False
+		for i := range sum {
+			sum[i] = f.Gate(parity, x[i], y[i], c)
+			if i < 31 {
+				c = f.Gate(majGate, x[i], y[i], c)
+			}
+		}
+	}
+	return sum
+}
+
+// Compression is a CNF encoding of the SHA-256 compression function of one
+// block, from a fixed intermediate hash value, reduced to Rounds rounds.
+// The 512 message bits are the variables 2 to 513, message bit i being bit
+// i of the block counting from the most significant bit of byte 0.
+type Compression struct {
+	*Formula
+	Rounds  int
+	Message [16]Word
+	Output  [8]Word
+}
+
+// Encode returns the encoding of sha2.Sha256CompressRounds(&h, block, rounds),
+// with every message bit free.
+func Encode(h [8]uint32, rounds int) *Compression {
+	if rounds < 0 || rounds > 64 {
+		panic("cnf: rounds must be 0-64")
+	}
+	c := &Compression{Formula: NewFormula(), Rounds: rounds}
+
+	// message variables first, so they have fixed numbers
+	for i := 0; i < sha2.Sha256BlocksizeBits; i++ {
+		c.NewVar()
+	}
+	for t := range c.Message {
+		for i := range c.Message[t] {
+			c.Message[t][i] = c.MessageBit(t*32 + 31 - i)
+		}
+	}
+
+	// message schedule, only as far as it is used
+	w := make([]Word, rounds)
+	copy(w, c.Message[:])
+	for t := 16; t < rounds; t++ {
+		w[t] = c.Add(c.LowerSigma1(w[t-2]), w[t-7], c.LowerSigma0(w[t-15]), w[t-16])
+	}
+
+	var v [8]Word
+	for i := range v {
+		v[i] = Const(h[i])
+	}
+	for t := 0; t < rounds; t++ {
+		a, b, cc, d, e, ff, g, hh := v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7]
+		t1 := c.Add(hh, c.UpperSigma1(e), c.Ch(e, ff, g), Const(sha2.Sha256K(t)), w[t])
+		t2 := c.Add(c.UpperSigma0(a), c.Maj(a, b, cc))
+		v = [8]Word{c.Add(t1, t2), a, b, cc, c.Add(d, t1), e, ff, g}
+	}
+
+	for i := range c.Output {
+		c.Output[i] = c.Add(Const(h[i]), v[i])
+	}
+	return c
+}
+
+// MessageBit returns the variable of message bit i.
+func (c *Compression) MessageBit(i int) Lit {
+	return Lit(2 + i)
+}
+
+// OutputBit returns the literal of output bit j, counting from the most
+// significant bit of H[0].  It may be a constant or a negation.
+func (c *Compression) OutputBit(j int) Lit {
+	return c.Output[j/32][31-j%32]
+}
+
+// FixMessage fixes every message bit to its value in block, except the bits
+// listed in free.
+func (c *Compression) FixMessage(block []byte, free []int) {
+	isFree := make(map[int]bool, len(free))
+	for _, i := range free {
+		isFree[i] = true
+	}
+	for i := 0; i < sha2.Sha256BlocksizeBits; i++ {
+		if isFree[i] {
+			continue
+		}
+		c.FixMessageBit(i, block[i/8]&(0x80>>uint(i%8)) != 0)
+	}
+}
+
+// FixMessageBit adds a unit clause fixing message bit i.
+func (c *Compression) FixMessageBit(i int, v bool) {
+	l := c.MessageBit(i)
+	if !v {
+		l = -l
+	}
+	c.AddClause(l)
+}
+
+// FixOutput fixes the listed output bits to their value in target, or all
+// 256 bits if bits is nil.
+func (c *Compression) FixOutput(target [8]uint32, bits []int) {
+	if bits == nil {
+		bits = make([]int, 256)
+		for j := range bits {
+			bits[j] = j
+		}
+	}
+	for _, j := range bits {
+		c.FixOutputBit(j, target[j/32]>>uint(31-j%32)&1 == 1)
+	}
+}
+
+// FixOutputBit adds a unit clause fixing output bit j.  If the bit is a
+// constant with the other value the formula becomes unsatisfiable.
+func (c *Compression) FixOutputBit(j int, v bool) {
+	l := c.OutputBit(j)
+	if !v {
+		l = -l
+	}
+	c.AddClause(l)
+}
+
+// Assignment returns the assignment, indexed by variable, that the encoding
+// gives to every variable when the message is block.
+func (c *Compression) Assignment(block []byte) []bool {
+	assign := make([]bool, c.Vars()+1)
+	for i := 0; i < sha2.Sha256BlocksizeBits; i++ {
+		assign[c.MessageBit(i)] = block[i/8]&(0x80>>uint(i%8)) != 0
+	}
+	c.Eval(assign)
+	return assign
+}
+
+// OutputOf returns the output words of an assignment.
+func (c *Compression) OutputOf(assign []bool) [8]uint32 {
+	var h [8]uint32
+	for i, w := range c.Output {
+		for b, l := range w {
+			if Value(assign, l) {
+				h[i] |= 1 << uint(b)
+			}
+		}
+	}
+	return h
+}
+
+// WriteDIMACS writes the formula with comments describing the variables.
+func (c *Compression) WriteDIMACS(w io.Writer) error {
+	comments := []string{
+		fmt.Sprintf("SHA-256 compression function, %d rounds", c.Rounds),
+		"message bit i (from the most significant bit of byte 0) is variable 2+i",
+		"output bits follow as: output <bit> <literal>, literal 1 is true and -1 false",
+	}
+	for j := 0; j < 256; j++ {
+		comments = append(comments, fmt.Sprintf("output %d %d", j, c.OutputBit(j)))
+	}
+	return c.Formula.WriteDIMACS(w, comments...)
+}
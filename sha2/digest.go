@@ -0,0 +1,102 @@
+package sha2
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+// Sha256Size is the size of a SHA-256 digest in bytes.
+const Sha256Size = 32
+
+// Digest is a running SHA-256 hash of a stream of bytes.
+// It implements hash.Hash.
+type Digest struct {
+	h   [8]uint32
+	x   [Sha256BlocksizeBytes]byte // partial block
+	nx  int                        // bytes in x
+	len uint64                     // bytes written
+}
+
+var _ hash.Hash = (*Digest)(nil)
+
+// New returns a Digest ready for writing.
+func New() *Digest {
+	d := &Digest{}
+	d.Reset()
+	return d
+}
+
+// Reset discards everything written.
+func (d *Digest) Reset() {
+	d.h = Sha256InitialHash()
+	d.nx = 0
+	d.len = 0
+}
+
+// Clone returns a copy of d, which goes on independently: the digests of
+// several messages with a common prefix need the prefix hashed only once.
+func (d *Digest) Clone() *Digest {
+	c := *d
+	return &c
+}
+
+// Size returns the digest size, 32 bytes.
+func (d *Digest) Size() int {
+	return Sha256Size
+}
+
+// BlockSize returns the block size, 64 bytes.
+func (d *Digest) BlockSize() int {
+	return Sha256BlocksizeBytes
+}
+
+// Write adds p to the hash.  It never returns an error.
+func (d *Digest) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+
+	// finish a partial block first
+	if d.nx > 0 {
+		c := copy(d.x[d.nx:], p)
+		d.nx += c
+		p = p[c:]
+		if d.nx < Sha256BlocksizeBytes {
+			return n, nil
+		}
+		block(&d.h, d.x[:], 0, 64, nil)
+		d.nx = 0
+	}
+
+	// whole blocks straight from p
+	for len(p) >= Sha256BlocksizeBytes {
+		block(&d.h, p[:Sha256BlocksizeBytes], 0, 64, nil)
+		p = p[Sha256BlocksizeBytes:]
+	}
+
+	d.nx = copy(d.x[:], p)
+	return n, nil
+}
+
+// Sum appends the digest of everything written so far to b.
+// The Digest is not changed, so writing can continue.
+func (d *Digest) Sum(b []byte) []byte {
+	s := d.Sum256()
+	return append(b, s[:]...)
+}
+
+// Sum256 returns the digest of everything written so far.
+// The Digest is not changed, so writing can continue.
+func (d *Digest) Sum256() [Sha256Size]byte {
+	// pad a copy, so d keeps its state
+	c := *d
+	c.Write(Padding(d.len))
+	if c.nx != 0 {
+		panic("Digest.Sum256: padding did not end on a block")
+	}
+
+	var result [Sha256Size]byte
+	for j := 0; j < 8; j++ {
+		binary.BigEndian.PutUint32(result[j*4:], c.h[j])
+	}
+	return result
+}
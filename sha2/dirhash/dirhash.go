@@ -0,0 +1,161 @@
+// Package dirhash computes a single digest for a tree of files, giving the
+// same "h1:" hashes the go command records in go.sum.
+//
+// The h1 hash is the SHA-256 of a summary with one line per file, sorted by
+// name:
+//
+//	<hex SHA-256 of the file>  <name>\n
+//
+// written as "h1:" and the digest in standard base64.
+package dirhash
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// Hash is a directory hash function.  It hashes the named files, using open
+// to read each one.
+type Hash func(files []string, open func(string) (io.ReadCloser, error)) (string, error)
+
+// DefaultHash is the hash used by HashDir and HashZip callers that don't care.
+var DefaultHash Hash = Hash1
+
+// Hash1 is the "h1:" directory hash.
+func Hash1(files []string, open func(string) (io.ReadCloser, error)) (string, error) {
+	names := append([]string(nil), files...)
+	sort.Strings(names)
+
+	// a newline in a name would let one summary line pass for two
+	for _, name := range names {
+		if strings.ContainsRune(name, '\n') {
+			return "", fmt.Errorf("dirhash: file name %q has a newline", name)
+		}
+	}
+
+	var summary bytes.Buffer
+	for _, name := range names {
+		sum, err := digest(name, open)
+		if err != nil {
+			return "", err
+		}
+		summary.WriteString(hex.EncodeToString(sum[:]))
+		summary.WriteString("  ")
+		summary.WriteString(name)
+		summary.WriteByte('\n')
+	}
+	d := sha2.New()
+	d.Write(summary.Bytes())
+	sum := d.Sum256()
+	return "h1:" + base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// digest returns the SHA-256 of the named file.
+func digest(name string, open func(string) (io.ReadCloser, error)) ([32]byte, error) {
+	r, err := open(name)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	defer r.Close()
+	d := sha2.New()
+	if _, err := io.Copy(d, r); err != nil {
+		return [32]byte{}, err
+	}
+	return d.Sum256(), nil
+}
+
+// HashDir hashes the tree rooted at dir, naming each file by its path
+// relative to dir joined to prefix, e.g. "golang.org/x/mod@v0.1.0".
+func HashDir(dir, prefix string, hash Hash) (string, error) {
+	paths, err := dirFiles(dir, prefix)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	return hash(names, func(name string) (io.ReadCloser, error) {
+		p, ok := paths[name]
+		if !ok {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		return os.Open(p)
+	})
+}
+
+// DirFiles returns the files in the tree rooted at dir, named as HashDir
+// names them, with forward slashes.
+func DirFiles(dir, prefix string) ([]string, error) {
+	paths, err := dirFiles(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// dirFiles maps the names of the files in the tree rooted at dir to their
+// paths.
+func dirFiles(dir, prefix string) (map[string]string, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, errors.New("dirhash: " + dir + " is not a directory")
+	}
+	paths := make(map[string]string)
+	err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		paths[path.Join(prefix, filepath.ToSlash(rel))] = p
+		return nil
+	})
+	return paths, err
+}
+
+// HashZip hashes the files in a zip archive, named as they are in the archive.
+// A module zip holds its files under "module@version/", so the result
+// matches HashDir of the unpacked module with that prefix.
+func HashZip(zipfile string, hash Hash) (string, error) {
+	z, err := zip.OpenReader(zipfile)
+	if err != nil {
+		return "", err
+	}
+	defer z.Close()
+	byName := make(map[string]*zip.File, len(z.File))
+	names := make([]string, 0, len(z.File))
+	for _, f := range z.File {
+		byName[f.Name] = f
+		names = append(names, f.Name)
+	}
+	return hash(names, func(name string) (io.ReadCloser, error) {
+		f, ok := byName[name]
+		if !ok {
+			return nil, &os.PathError{Op: "open", Path: zipfile + ":" + name, Err: os.ErrNotExist}
+		}
+		return f.Open()
+	})
+}
@@ -0,0 +1,89 @@
+package dirhash_test
+
+import (
+	"archive/zip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jwatson0/go/gosha256/sha2/dirhash"
+)
+
+// files and their h1 hash under prefix, computed independently with
+// sha256sum-style lines and Python's hashlib
+var (
+	prefix = "example.com/m@v1.0.0"
+	files  = map[string]string{
+		"a.txt":      "hello\n",
+		"empty":      "",
+		"sub/sub.go": "package sub\n",
+	}
+	want = "h1:6qjv8VUQ3aFkON4f3kKeienHh+J0E3WyIUcsZurduL8="
+)
+
+func TestHashDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := dirhash.HashDir(dir, prefix, dirhash.Hash1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h != want {
+		t.Errorf("dirhash.HashDir => %s, want %s", h, want)
+	}
+
+	if _, err := dirhash.HashDir(filepath.Join(dir, "a.txt"), prefix, dirhash.Hash1); err == nil {
+		t.Errorf("dirhash.HashDir of a file should fail")
+	}
+}
+
+func TestHashZip(t *testing.T) {
+	f, err := ioutil.TempFile("", "dirhash*.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	z := zip.NewWriter(f)
+	for name, data := range files {
+		w, err := z.Create(prefix + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, data)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	h, err := dirhash.HashZip(f.Name(), dirhash.DefaultHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h != want {
+		t.Errorf("dirhash.HashZip => %s, want %s", h, want)
+	}
+}
+
+func TestHash1Newline(t *testing.T) {
+	open := func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+	if _, err := dirhash.Hash1([]string{"bad\nname"}, open); err == nil {
+		t.Errorf("dirhash.Hash1 accepted a name with a newline")
+	}
+}
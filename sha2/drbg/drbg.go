@@ -0,0 +1,288 @@
+// Package drbg implements the Hash_DRBG and HMAC_DRBG deterministic random
+// bit generators of NIST SP 800-90A Rev. 1 with SHA-256, for reproducible
+// pseudorandom streams: the same entropy input, nonce and personalization
+// string always give the same bytes.
+//
+// A generator must be reseeded after ReseedInterval requests; until then
+// Generate returns ErrReseedRequired.  The entropy input is the caller's to
+// supply, from crypto/rand for secrets or a fixed seed for test data.
+package drbg
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+const (
+	// MinEntropy is the least entropy input accepted, in bytes: the 256-bit
+	// security strength of SHA-256.
+	MinEntropy = 32
+
+	// MaxRequest is the most bytes Generate returns at once, 2^19 bits.
+	MaxRequest = 1 << 16
+
+	// MaxReseedInterval is the most requests allowed between reseeds, and
+	// the default.
+	MaxReseedInterval = 1 << 48
+
+	seedLen = 440 / 8 // of Hash_DRBG with SHA-256
+)
+
+var (
+	// ErrReseedRequired is returned by Generate once ReseedInterval
+	// requests have been made since the last seeding.
+	ErrReseedRequired = errors.New("drbg: reseed required")
+
+	// ErrEntropy is returned for an entropy input shorter than MinEntropy.
+	ErrEntropy = errors.New("drbg: entropy input too short")
+
+	// ErrRequest is returned for a request of more than MaxRequest bytes.
+	ErrRequest = errors.New("drbg: request too large")
+)
+
+// DRBG is a deterministic random bit generator.
+type DRBG interface {
+	// Reseed mixes in new entropy input and optional additional input.
+	Reseed(entropy, additional []byte) error
+
+	// Generate fills out, with optional additional input mixed in first.
+	Generate(out, additional []byte) error
+}
+
+// state is what both generators share: the request counter and its limit.
+type state struct {
+	counter  uint64 // reseed_counter: requests since seeding, plus 1
+	interval uint64
+}
+
+// SetReseedInterval sets the number of requests allowed between reseeds,
+// from 1 to MaxReseedInterval.
+func (s *state) SetReseedInterval(n uint64) {
+	if n < 1 || n > MaxReseedInterval {
+		panic("drbg: reseed interval out of range")
+	}
+	s.interval = n
+}
+
+// check is the start of every request.
+func (s *state) check(out []byte) error {
+	if len(out) > MaxRequest {
+		return ErrRequest
+	}
+	if s.counter > s.interval {
+		return ErrReseedRequired
+	}
+	return nil
+}
+
+func sum(parts ...[]byte) [32]byte {
+	d := sha2.New()
+	for _, p := range parts {
+		d.Write(p)
+	}
+	return d.Sum256()
+}
+
+// HashDRBG is Hash_DRBG, section 10.1.1.
+type HashDRBG struct {
+	state
+	v, c [seedLen]byte
+}
+
+// NewHashDRBG instantiates a Hash_DRBG.  The nonce and personalization
+// string may be empty.
+func NewHashDRBG(entropy, nonce, personalization []byte) (*HashDRBG, error) {
+	if len(entropy) < MinEntropy {
+		return nil, ErrEntropy
+	}
+	d := &HashDRBG{state: state{interval: MaxReseedInterval}}
+	d.seed(nil, entropy, nonce, personalization)
+	return d, nil
+}
+
+// hashDF is Hash_df, section 10.3.1, returning seedLen bytes.
+func hashDF(input ...[]byte) (out [seedLen]byte) {
+	var head [5]byte
+	binary.BigEndian.PutUint32(head[1:], seedLen*8)
+	var b []byte
+	for i := byte(1); len(b) < seedLen; i++ {
+		head[0] = i
+		h := sum(append([][]byte{head[:]}, input...)...)
+		b = append(b, h[:]...)
+	}
+	copy(out[:], b)
+	return out
+}
+
+// seed sets V and C from the seed material, prefixed with 0x01 || V when
+// reseeding.
+func (d *HashDRBG) seed(prefix []byte, material ...[]byte) {
+	d.v = hashDF(append([][]byte{prefix}, material...)...)
+	d.c = hashDF([]byte{0}, d.v[:])
+	d.counter = 1
+}
+
+// Reseed implements DRBG.
+func (d *HashDRBG) Reseed(entropy, additional []byte) error {
+	if len(entropy) < MinEntropy {
+		return ErrEntropy
+	}
+	v := d.v
+	d.seed(append([]byte{1}, v[:]...), entropy, additional)
+	return nil
+}
+
+// add adds x, big-endian, to v modulo 2^440.
+func add(v *[seedLen]byte, x []byte) {
+	carry := 0
+	for i, j := seedLen-1, len(x)-1; i >= 0; i, j = i-1, j-1 {
+		s := int(v[i]) + carry
+		if j >= 0 {
+			s += int(x[j])
+		}
+		v[i] = byte(s)
+		carry = s >> 8
+	}
+}
+
+// Generate implements DRBG.
+func (d *HashDRBG) Generate(out, additional []byte) error {
+	if err := d.check(out); err != nil {
+		return err
+	}
+	if len(additional) > 0 {
+		w := sum([]byte{2}, d.v[:], additional)
+		add(&d.v, w[:])
+	}
+	// Hashgen
+	data := d.v
+	for b := out; len(b) > 0; {
+		h := sum(data[:])
+		b = b[copy(b, h[:]):]
+		add(&data, []byte{1})
+	}
+	h := sum([]byte{3}, d.v[:])
+	add(&d.v, h[:])
+	add(&d.v, d.c[:])
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], d.counter)
+	add(&d.v, n[:])
+	d.counter++
+	return nil
+}
+
+// HMACDRBG is HMAC_DRBG, section 10.1.2.
+type HMACDRBG struct {
+	state
+	k, v [32]byte
+}
+
+// NewHMACDRBG instantiates an HMAC_DRBG.  The nonce and personalization
+// string may be empty.
+func NewHMACDRBG(entropy, nonce, personalization []byte) (*HMACDRBG, error) {
+	if len(entropy) < MinEntropy {
+		return nil, ErrEntropy
+	}
+	d := &HMACDRBG{state: state{interval: MaxReseedInterval}}
+	for i := range d.v {
+		d.v[i] = 1
+	}
+	d.update(entropy, nonce, personalization)
+	d.counter = 1
+	return d, nil
+}
+
+func (d *HMACDRBG) mac(parts ...[]byte) (out [32]byte) {
+	m := hmac.New(func() hash.Hash { return sha2.New() }, d.k[:])
+	for _, p := range parts {
+		m.Write(p)
+	}
+	m.Sum(out[:0])
+	return out
+}
+
+// update is HMAC_DRBG_Update, with the provided data in parts.
+func (d *HMACDRBG) update(provided ...[]byte) {
+	empty := true
+	for _, p := range provided {
+		empty = empty && len(p) == 0
+	}
+	d.k = d.mac(append([][]byte{d.v[:], {0}}, provided...)...)
+	d.v = d.mac(d.v[:])
+	if empty {
+		return
+	}
+	d.k = d.mac(append([][]byte{d.v[:], {1}}, provided...)...)
+	d.v = d.mac(d.v[:])
+}
+
+// Reseed implements DRBG.
+func (d *HMACDRBG) Reseed(entropy, additional []byte) error {
+	if len(entropy) < MinEntropy {
+		return ErrEntropy
+	}
+	d.update(entropy, additional)
+	d.counter = 1
+	return nil
+}
+
+// Generate implements DRBG.
+func (d *HMACDRBG) Generate(out, additional []byte) error {
+	if err := d.check(out); err != nil {
+		return err
+	}
+	if len(additional) > 0 {
+		d.update(additional)
+	}
+	for b := out; len(b) > 0; {
+		d.v = d.mac(d.v[:])
+		b = b[copy(b, d.v[:]):]
+	}
+	d.update(additional)
+	d.counter++
+	return nil
+}
+
+// Reader reads the output of a DRBG, MaxRequest bytes at a time at most.
+type Reader struct {
+	d       DRBG
+	entropy io.Reader
+}
+
+// NewReader returns a Reader of d's output.  When d needs reseeding, it is
+// reseeded with MinEntropy bytes from entropy, or if entropy is nil, Read
+// returns ErrReseedRequired.
+func NewReader(d DRBG, entropy io.Reader) *Reader {
+	return &Reader{d: d, entropy: entropy}
+}
+
+// Read fills p.
+func (r *Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		b := p[n:]
+		if len(b) > MaxRequest {
+			b = b[:MaxRequest]
+		}
+		err := r.d.Generate(b, nil)
+		if err == ErrReseedRequired && r.entropy != nil {
+			var e [MinEntropy]byte
+			if _, err = io.ReadFull(r.entropy, e[:]); err == nil {
+				err = r.d.Reseed(e[:], nil)
+			}
+			if err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			return n, err
+		}
+		n += len(b)
+	}
+	return n, nil
+}
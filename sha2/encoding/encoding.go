@@ -0,0 +1,176 @@
+// Package encoding formats SHA-256 digests as text, and parses them back.
+//
+//	Hex        ba7816bf...
+//	HexUpper   BA7816BF...
+//	Base64     ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0=
+//	Base64URL  ungWv48Bz-pBQUDeXa4iI7ADYaOWF3qctBD_YfIAFa0 (unpadded)
+//	Base32     XJ4BNP4PAHH6UQKBIDPF3LRCEOYAGYNDSYLXVHFUCD7WD4QACWWQ====
+//	Multihash  1220ba7816bf... (hex of the 0x12 0x20 multihash prefix and digest)
+//	SRI        sha256-ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0=
+//	OCI        sha256:ba7816bf...
+package encoding
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// Format is a text form of a digest.
+type Format int
+
+const (
+	Hex Format = iota
+	HexUpper
+	Base64
+	Base64URL
+	Base32
+	Multihash
+	SRI
+	OCI
+)
+
+var formatNames = []string{
+	Hex:       "hex",
+	HexUpper:  "hex-upper",
+	Base64:    "base64",
+	Base64URL: "base64url",
+	Base32:    "base32",
+	Multihash: "multihash",
+	SRI:       "sri",
+	OCI:       "oci",
+}
+
+// Formats lists every Format.
+func Formats() []Format {
+	f := make([]Format, len(formatNames))
+	for i := range f {
+		f[i] = Format(i)
+	}
+	return f
+}
+
+func (f Format) String() string {
+	if f < 0 || int(f) >= len(formatNames) {
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+	return formatNames[f]
+}
+
+// ParseFormat returns the Format named s, as returned by Format.String.
+func ParseFormat(s string) (Format, error) {
+	for i, n := range formatNames {
+		if strings.EqualFold(s, n) {
+			return Format(i), nil
+		}
+	}
+	return Hex, fmt.Errorf("encoding: unknown format %q, want one of %s", s, strings.Join(formatNames, ", "))
+}
+
+// Multihash codes for SHA-256: function 0x12, 32 byte length.
+const (
+	MultihashCode   = 0x12
+	MultihashLength = 0x20
+)
+
+const (
+	sriPrefix = "sha256-"
+	ociPrefix = "sha256:"
+)
+
+// MultihashBytes returns the binary multihash of d.
+func MultihashBytes(d [sha2.Sha256Size]byte) []byte {
+	return append([]byte{MultihashCode, MultihashLength}, d[:]...)
+}
+
+// ParseMultihash returns the digest in a binary SHA-256 multihash.
+func ParseMultihash(b []byte) ([sha2.Sha256Size]byte, error) {
+	var d [sha2.Sha256Size]byte
+	if len(b) != 2+sha2.Sha256Size || b[0] != MultihashCode || b[1] != MultihashLength {
+		return d, fmt.Errorf("encoding: not a sha2-256 multihash")
+	}
+	copy(d[:], b[2:])
+	return d, nil
+}
+
+// Encode returns d in format f.
+func Encode(f Format, d [sha2.Sha256Size]byte) string {
+	switch f {
+	case Hex:
+		return hex.EncodeToString(d[:])
+	case HexUpper:
+		return strings.ToUpper(hex.EncodeToString(d[:]))
+	case Base64:
+		return base64.StdEncoding.EncodeToString(d[:])
+	case Base64URL:
+		return base64.RawURLEncoding.EncodeToString(d[:])
+	case Base32:
+		return base32.StdEncoding.EncodeToString(d[:])
+	case Multihash:
+		return hex.EncodeToString(MultihashBytes(d))
+	case SRI:
+		return sriPrefix + base64.StdEncoding.EncodeToString(d[:])
+	case OCI:
+		return ociPrefix + hex.EncodeToString(d[:])
+	}
+	panic("encoding: unknown format " + f.String())
+}
+
+// Decode parses s in format f, checking its prefix and length.
+func Decode(f Format, s string) ([sha2.Sha256Size]byte, error) {
+	var d [sha2.Sha256Size]byte
+	var b []byte
+	var err error
+
+	switch f {
+	case Hex, HexUpper:
+		b, err = decodeHex(s, f == Hex)
+	case Base64:
+		b, err = base64.StdEncoding.Strict().DecodeString(s)
+	case Base64URL:
+		// the padding is optional
+		b, err = base64.RawURLEncoding.Strict().DecodeString(strings.TrimRight(s, "="))
+	case Base32:
+		b, err = base32.StdEncoding.DecodeString(s)
+	case Multihash:
+		if b, err = hex.DecodeString(s); err == nil {
+			return ParseMultihash(b)
+		}
+	case SRI:
+		if !strings.HasPrefix(s, sriPrefix) {
+			return d, fmt.Errorf("encoding: %s digest does not start with %q", f, sriPrefix)
+		}
+		b, err = base64.StdEncoding.Strict().DecodeString(s[len(sriPrefix):])
+	case OCI:
+		if !strings.HasPrefix(s, ociPrefix) {
+			return d, fmt.Errorf("encoding: %s digest does not start with %q", f, ociPrefix)
+		}
+		// the OCI image spec only allows lower case hex
+		b, err = decodeHex(s[len(ociPrefix):], true)
+	default:
+		return d, fmt.Errorf("encoding: unknown format %s", f)
+	}
+	if err != nil {
+		return d, fmt.Errorf("encoding: bad %s digest: %v", f, err)
+	}
+	if len(b) != sha2.Sha256Size {
+		return d, fmt.Errorf("encoding: %s digest is %d bytes, want %d", f, len(b), sha2.Sha256Size)
+	}
+	copy(d[:], b)
+	return d, nil
+}
+
+// decodeHex decodes hex digits, all lower case or all upper case
+func decodeHex(s string, lower bool) ([]byte, error) {
+	if lower && strings.ToLower(s) != s {
+		return nil, fmt.Errorf("hex digits must be lower case")
+	}
+	if !lower && strings.ToUpper(s) != s {
+		return nil, fmt.Errorf("hex digits must be upper case")
+	}
+	return hex.DecodeString(s)
+}
@@ -0,0 +1,76 @@
+package encoding_test
+
+import (
+	"testing"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/encoding"
+)
+
+func TestEncode(t *testing.T) {
+	d := sha2.Sha256([]byte("abc"))
+	v := []struct {
+		f   encoding.Format
+		out string
+	}{
+		{encoding.Hex, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{encoding.HexUpper, "BA7816BF8F01CFEA414140DE5DAE2223B00361A396177A9CB410FF61F20015AD"},
+		{encoding.Base64, "ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0="},
+		{encoding.Base64URL, "ungWv48Bz-pBQUDeXa4iI7ADYaOWF3qctBD_YfIAFa0"},
+		{encoding.Base32, "XJ4BNP4PAHH6UQKBIDPF3LRCEOYAGYNDSYLXVHFUCD7WD4QACWWQ===="},
+		{encoding.Multihash, "1220ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{encoding.SRI, "sha256-ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0="},
+		{encoding.OCI, "sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+	if len(v) != len(encoding.Formats()) {
+		t.Fatalf("test covers %d formats, there are %d", len(v), len(encoding.Formats()))
+	}
+	for _, a := range v {
+		if s := encoding.Encode(a.f, d); s != a.out {
+			t.Errorf("encoding.Encode(%s) => %s, want %s", a.f, s, a.out)
+		}
+		got, err := encoding.Decode(a.f, a.out)
+		if err != nil || got != d {
+			t.Errorf("encoding.Decode(%s, %s) => %x, %v", a.f, a.out, got, err)
+		}
+		f, err := encoding.ParseFormat(a.f.String())
+		if err != nil || f != a.f {
+			t.Errorf("encoding.ParseFormat(%s) => %s, %v", a.f.String(), f, err)
+		}
+	}
+
+	// padding is optional for base64url
+	if got, err := encoding.Decode(encoding.Base64URL, "ungWv48Bz-pBQUDeXa4iI7ADYaOWF3qctBD_YfIAFa0="); err != nil || got != d {
+		t.Errorf("encoding.Decode(base64url) with padding => %x, %v", got, err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	v := []struct {
+		f  encoding.Format
+		in string
+	}{
+		{encoding.Hex, "ba7816bf"},
+		{encoding.Hex, "BA7816BF8F01CFEA414140DE5DAE2223B00361A396177A9CB410FF61F20015AD"},
+		{encoding.Hex, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad00"},
+		{encoding.HexUpper, "zz"},
+		{encoding.Base64, "ungWv48Bz-pBQUDeXa4iI7ADYaOWF3qctBD_YfIAFa0="},
+		{encoding.Base64, "ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIA"},
+		{encoding.Base32, "XJ4BNP4PAHH6UQKB"},
+		{encoding.Multihash, "1320ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{encoding.Multihash, "1220ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f200"},
+		{encoding.SRI, "sha384-ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0="},
+		{encoding.SRI, "ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0="},
+		{encoding.OCI, "sha256:BA7816BF8F01CFEA414140DE5DAE2223B00361A396177A9CB410FF61F20015AD"},
+		{encoding.OCI, "sha512:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{encoding.Format(99), ""},
+	}
+	for _, a := range v {
+		if d, err := encoding.Decode(a.f, a.in); err == nil {
+			t.Errorf("encoding.Decode(%s, %s) => %x, want an error", a.f, a.in, d)
+		}
+	}
+	if _, err := encoding.ParseFormat("base58"); err == nil {
+		t.Errorf("encoding.ParseFormat(base58) should fail")
+	}
+}
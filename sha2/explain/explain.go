@@ -0,0 +1,146 @@
+// Package explain renders every intermediate value of a SHA-256 computation,
+// laid out like the NIST "example values" documents for FIPS 180:
+// the padded message, the message schedule, the working variables after
+// each round, the intermediate hash values and the final digest.
+package explain
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// Format selects how a Report is rendered.
+type Format int
+
+const (
+	Text Format = iota
+	Markdown
+	HTML
+)
+
+// ParseFormat returns the Format named by s: "text", "markdown" (or "md"), or "html".
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "text", "txt":
+		return Text, nil
+	case "markdown", "md":
+		return Markdown, nil
+	case "html":
+		return HTML, nil
+	}
+	return Text, fmt.Errorf("explain: unknown format %q", s)
+}
+
+// Block holds the intermediate values of one 512-bit block.
+type Block struct {
+	Data   []byte
+	W      [64]uint32
+	Rounds [64]sha2.Round
+
+	// hash value before the block, working variables after round 63, and
+	// the hash value after the block
+	Prev [8]uint32
+	Work [8]uint32
+	Next [8]uint32
+}
+
+// Report holds everything computed while hashing Message.
+type Report struct {
+	Message []byte
+	Padding []byte
+	Blocks  []Block
+	Digest  [32]byte
+}
+
+// New hashes m and records the intermediate values.
+func New(m []byte) *Report {
+	r := &Report{
+		Message: m,
+		Padding: sha2.Padding(uint64(len(m))),
+	}
+	r.Blocks = make([]Block, (len(m)+len(r.Padding))/sha2.Sha256BlocksizeBytes)
+	r.Digest = sha2.Sha256Traced(m, &sha2.Trace{
+		Block: func(n int, chunk []byte) {
+			r.Blocks[n].Data = append([]byte(nil), chunk...)
+		},
+		Schedule: func(n int, w *[64]uint32) {
+			r.Blocks[n].W = *w
+		},
+		Round: func(n int, rd sha2.Round) {
+			r.Blocks[n].Rounds[rd.T] = rd
+		},
+		Digest: func(n int, prev, work, next [8]uint32) {
+			r.Blocks[n].Prev = prev
+			r.Blocks[n].Work = work
+			r.Blocks[n].Next = next
+		},
+	})
+	return r
+}
+
+// Title names the example the way the NIST documents do.
+func (r *Report) Title() string {
+	if len(r.Blocks) == 1 {
+		return "SHA-256 Example (One-Block Message)"
+	}
+	return "SHA-256 Example (Multi-Block Message)"
+}
+
+// Quoted returns the message as a quoted ASCII string.
+func (r *Report) Quoted() string {
+	return strconv.QuoteToASCII(string(r.Message))
+}
+
+// Bits returns the message length in bits.
+func (r *Report) Bits() int {
+	return len(r.Message) * 8
+}
+
+// Words returns the padded message as rows of eight hex words.
+func (r *Report) Words() []string {
+	p := make([]byte, 0, len(r.Message)+len(r.Padding))
+	p = append(p, r.Message...)
+	p = append(p, r.Padding...)
+
+	var rows []string
+	for i := 0; i < len(p); i += 32 {
+		row := make([]string, 8)
+		for j := range row {
+			row[j] = fmt.Sprintf("%X", p[i+j*4:i+j*4+4])
+		}
+		rows = append(rows, strings.Join(row, " "))
+	}
+	return rows
+}
+
+// Write renders the report to w in format f.
+func (r *Report) Write(w io.Writer, f Format) error {
+	switch f {
+	case Text:
+		return r.WriteText(w)
+	case Markdown:
+		return r.WriteMarkdown(w)
+	case HTML:
+		return r.WriteHTML(w)
+	}
+	return fmt.Errorf("explain: unknown format %d", f)
+}
+
+// WriteText renders the report as plain text.
+func (r *Report) WriteText(w io.Writer) error {
+	return textTemplate.Execute(w, r)
+}
+
+// WriteMarkdown renders the report as Markdown.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	return markdownTemplate.Execute(w, r)
+}
+
+// WriteHTML renders the report as a standalone HTML page.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
@@ -0,0 +1,81 @@
+package explain_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jwatson0/go/gosha256/sha2/explain"
+)
+
+// Lines taken from the NIST example values for FIPS 180
+// https://csrc.nist.gov/CSRC/media/Projects/Cryptographic-Standards-and-Guidelines/documents/examples/SHA256.pdf
+func TestWriteText(t *testing.T) {
+	v := []struct {
+		in    string
+		lines []string
+	}{
+		{"abc", []string{
+			"SHA-256 Example (One-Block Message)",
+			"  61626380 00000000 00000000 00000000 00000000 00000000 00000000 00000000",
+			"  00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000018",
+			"  W[0] = 61626380",
+			"  W[15] = 00000018",
+			"t= 0: 5D6AEBCD 6A09E667 BB67AE85 3C6EF372 FA2A4622 510E527F 9B05688C 1F83D9AB",
+			"t= 1: 5A6AD9AD 5D6AEBCD 6A09E667 BB67AE85 78CE7989 FA2A4622 510E527F 9B05688C",
+			"t=63: 506E3058 D39A2165 04D24D6C B85E2CE9 5EF50F24 FB121210 948D25B6 961F4894",
+			"H[0] = 506E3058 + 6A09E667 = BA7816BF",
+			"H[7] = 961F4894 + 5BE0CD19 = F20015AD",
+			"Message Digest is BA7816BF 8F01CFEA 414140DE 5DAE2223 B00361A3 96177A9C B410FF61 F20015AD",
+		}},
+		{"abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq", []string{
+			"SHA-256 Example (Multi-Block Message)",
+			"Block 1 of 2",
+			"t= 0: 5D6AEBB1 6A09E667 BB67AE85 3C6EF372 FA2A4606 510E527F 9B05688C 1F83D9AB",
+			"t=63: 1BDC6F6F 86126910 F6F443F8 BCFCE922 25D2430A 2FC08F85 ACC75916 962D8621",
+			"H[0] = 1BDC6F6F + 6A09E667 = 85E655D6",
+			"H[7] = 962D8621 + 5BE0CD19 = F20E533A",
+			"Block 2 of 2",
+			"  W[15] = 000001C0",
+			"t= 0: 7C20C838 85E655D6 417A1795 3363376A 4670AE6E 76E09589 CAC5F811 CC4B32C1",
+			"t=63: 9EA7148B 908C2123 B25CEF29 A9F181DD 2C5C4ED0 9A392956 2AA1BB13 27CCB387",
+			"H[0] = 9EA7148B + 85E655D6 = 248D6A61",
+			"Message Digest is 248D6A61 D20638B8 E5C02693 0C3E6039 A33CE459 64FF2167 F6ECEDD4 19DB06C1",
+		}},
+	}
+	for i, a := range v {
+		var buf bytes.Buffer
+		if err := explain.New([]byte(a.in)).WriteText(&buf); err != nil {
+			t.Fatalf("explain.WriteText failure #%d: %s", i, err)
+		}
+		got := "\n" + buf.String()
+		for _, l := range a.lines {
+			if !strings.Contains(got, "\n"+l+"\n") {
+				t.Errorf("explain.WriteText failure #%d: output missing line\n    %s", i, l)
+			}
+		}
+	}
+}
+
+func TestWriteFormats(t *testing.T) {
+	r := explain.New([]byte("<abc>"))
+	for _, name := range []string{"text", "markdown", "html"} {
+		f, err := explain.ParseFormat(name)
+		if err != nil {
+			t.Fatalf("explain.ParseFormat(%q) failed: %s", name, err)
+		}
+		var buf bytes.Buffer
+		if err := r.Write(&buf, f); err != nil {
+			t.Fatalf("explain.Write(%s) failed: %s", name, err)
+		}
+		if !strings.Contains(buf.String(), "Message Digest") {
+			t.Errorf("explain.Write(%s) has no digest", name)
+		}
+		if name == "html" && strings.Contains(buf.String(), "<abc>") {
+			t.Errorf("explain.Write(html) did not escape the message")
+		}
+	}
+	if _, err := explain.ParseFormat("pdf"); err == nil {
+		t.Errorf("explain.ParseFormat(pdf) should fail")
+	}
+}
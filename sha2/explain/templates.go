@@ -0,0 +1,145 @@
+package explain
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+)
+
+var funcs = template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+	"word": func(x uint32) string {
+		return fmt.Sprintf("%8.8X", x)
+	},
+	"words": func(s [8]uint32) string {
+		w := make([]string, len(s))
+		for i, x := range s {
+			w[i] = fmt.Sprintf("%8.8X", x)
+		}
+		return strings.Join(w, " ")
+	},
+	"digest": func(d [32]byte) string {
+		w := make([]string, 8)
+		for i := range w {
+			w[i] = fmt.Sprintf("%X", d[i*4:i*4+4])
+		}
+		return strings.Join(w, " ")
+	},
+}
+
+var textTemplate = template.Must(template.New("text").Funcs(funcs).Parse(
+	`{{.Title}}
+
+Message: {{.Quoted}} ({{.Bits}} bits)
+
+Padded Message:
+{{range .Words}}  {{.}}
+{{end}}{{range $i, $b := .Blocks}}
+Block {{inc $i}} of {{len $.Blocks}}
+
+Initial Hash Value:
+{{range $j, $h := $b.Prev}}  H[{{$j}}] = {{word $h}}
+{{end}}
+Block Contents:
+{{range $t, $w := $b.W}}{{if lt $t 16}}  W[{{$t}}] = {{word $w}}
+{{end}}{{end}}
+Message Schedule:
+{{range $t, $w := $b.W}}{{if ge $t 16}}  W[{{$t}}] = {{word $w}}
+{{end}}{{end}}
+          A        B        C        D        E        F        G        H
+{{range $b.Rounds}}t={{printf "%2d" .T}}: {{words .State}}
+{{end}}
+{{range $j, $h := $b.Next}}H[{{$j}}] = {{word (index $b.Work $j)}} + {{word (index $b.Prev $j)}} = {{word $h}}
+{{end}}{{end}}
+Message Digest is {{digest .Digest}}
+`))
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(funcs).Parse(
+	`# {{.Title}}
+
+Message: ` + "`{{.Quoted}}`" + ` ({{.Bits}} bits)
+
+## Padded Message
+
+` + "```" + `
+{{range .Words}}{{.}}
+{{end}}` + "```" + `
+{{range $i, $b := .Blocks}}
+## Block {{inc $i}} of {{len $.Blocks}}
+
+### Initial Hash Value
+
+| i | H[i] |
+|---|------|
+{{range $j, $h := $b.Prev}}| {{$j}} | ` + "`{{word $h}}`" + ` |
+{{end}}
+### Message Schedule
+
+| t | W[t] |
+|---|------|
+{{range $t, $w := $b.W}}| {{$t}} | ` + "`{{word $w}}`" + ` |
+{{end}}
+### Rounds
+
+| t | A | B | C | D | E | F | G | H |
+|---|---|---|---|---|---|---|---|---|
+{{range $b.Rounds}}| {{.T}} |{{range .State}} ` + "`{{word .}}`" + ` |{{end}}
+{{end}}
+### Intermediate Hash Value
+
+| i | a..h | H[i] | sum |
+|---|------|------|-----|
+{{range $j, $h := $b.Next}}| {{$j}} | ` + "`{{word (index $b.Work $j)}}`" + ` | ` + "`{{word (index $b.Prev $j)}}`" + ` | ` + "`{{word $h}}`" + ` |
+{{end}}{{end}}
+## Message Digest
+
+` + "`{{digest .Digest}}`" + `
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(htmltemplate.FuncMap(funcs)).Parse(
+	`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; font-family: monospace; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Message: <code>{{.Quoted}}</code> ({{.Bits}} bits)</p>
+<h2>Padded Message</h2>
+<pre>{{range .Words}}{{.}}
+{{end}}</pre>
+{{range $i, $b := .Blocks}}
+<h2>Block {{inc $i}} of {{len $.Blocks}}</h2>
+<h3>Initial Hash Value</h3>
+<table>
+<tr><th>i</th><th>H[i]</th></tr>
+{{range $j, $h := $b.Prev}}<tr><td>{{$j}}</td><td>{{word $h}}</td></tr>
+{{end}}</table>
+<h3>Message Schedule</h3>
+<table>
+<tr><th>t</th><th>W[t]</th></tr>
+{{range $t, $w := $b.W}}<tr><td>{{$t}}</td><td>{{word $w}}</td></tr>
+{{end}}</table>
+<h3>Rounds</h3>
+<table>
+<tr><th>t</th><th>A</th><th>B</th><th>C</th><th>D</th><th>E</th><th>F</th><th>G</th><th>H</th></tr>
+{{range $b.Rounds}}<tr><td>{{.T}}</td>{{range .State}}<td>{{word .}}</td>{{end}}</tr>
+{{end}}</table>
+<h3>Intermediate Hash Value</h3>
+<table>
+<tr><th>i</th><th>a..h</th><th>H[i]</th><th>sum</th></tr>
+{{range $j, $h := $b.Next}}<tr><td>{{$j}}</td><td>{{word (index $b.Work $j)}}</td><td>{{word (index $b.Prev $j)}}</td><td>{{word $h}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Message Digest</h2>
+<p><code>{{digest .Digest}}</code></p>
+</body>
+</html>
+`))
@@ -0,0 +1,249 @@
+// Package filecache remembers the SHA-256 digests of files, keyed by their
+// device, inode, size, modification time and change time, so an unchanged
+// file need not be hashed again.  Any change to the metadata is a miss.
+//
+// The cache is an append-only log of fixed-size records:
+//
+//	dev, ino, size, mtime ns, ctime ns  8 bytes each, big-endian
+//	digest                              32 bytes
+//	check                               first 4 bytes of the SHA-256 of the above
+//
+// after an 8 byte header.  A later record for the same device and inode
+// replaces an earlier one.  A torn or corrupt record ends the log and is cut
+// off when the cache is opened, and the log is compacted, on opening or on
+// adding a record, when most of its records have been replaced.
+//
+// Several processes may share a cache.  Records appended by one while
+// another compacts the log can be lost, which only costs hashing again.
+package filecache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// Key identifies the contents of a file by its metadata.  Times are in
+// nanoseconds since the Unix epoch.
+type Key struct {
+	Dev, Ino     uint64
+	Size         int64
+	Mtime, Ctime int64
+}
+
+// id is the file a key belongs to, whatever its contents
+type id struct {
+	dev, ino uint64
+}
+
+type entry struct {
+	key    Key
+	digest [32]byte
+}
+
+const (
+	magic      = "gsha256c" // header
+	recordSize = 5*8 + 32 + 4
+
+	// compact when more than this many records are dead
+	minDead = 1024
+)
+
+var errCorrupt = errors.New("filecache: not a cache file")
+
+// Cache is a file-backed digest cache, safe for concurrent use.
+type Cache struct {
+	path    string
+	mu      sync.Mutex
+	f       *os.File
+	m       map[id]entry
+	records int // in the log, live or dead
+}
+
+// Open opens the cache at path, creating it if it doesn't exist.
+func Open(path string) (*Cache, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cache{path: path, f: f, m: make(map[id]entry)}
+	if err := c.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if c.wasteful() {
+		if err := c.Compact(); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// load reads the log, writing the header of a new one and cutting off a
+// torn record at the end.
+func (c *Cache) load() error {
+	fi, err := c.f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() == 0 {
+		_, err := c.f.Write([]byte(magic))
+		return err
+	}
+
+	r := bufio.NewReader(c.f)
+	var head [len(magic)]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil || string(head[:]) != magic {
+		return errCorrupt
+	}
+	good := int64(len(magic))
+	var rec [recordSize]byte
+	for {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			break
+		}
+		e, ok := decode(rec[:])
+		if !ok {
+			break
+		}
+		c.m[id{e.key.Dev, e.key.Ino}] = e
+		c.records++
+		good += recordSize
+	}
+	if good < fi.Size() {
+		return c.f.Truncate(good)
+	}
+	return nil
+}
+
+func encode(e entry) []byte {
+	b := make([]byte, recordSize)
+	binary.BigEndian.PutUint64(b[0:], e.key.Dev)
+	binary.BigEndian.PutUint64(b[8:], e.key.Ino)
+	binary.BigEndian.PutUint64(b[16:], uint64(e.key.Size))
+	binary.BigEndian.PutUint64(b[24:], uint64(e.key.Mtime))
+	binary.BigEndian.PutUint64(b[32:], uint64(e.key.Ctime))
+	copy(b[40:], e.digest[:])
+	check := sha2.Sha256(b[:72])
+	copy(b[72:], check[:4])
+	return b
+}
+
+func decode(b []byte) (e entry, ok bool) {
+	check := sha2.Sha256(b[:72])
+	if !bytes.Equal(check[:4], b[72:]) {
+		return e, false
+	}
+	e.key.Dev = binary.BigEndian.Uint64(b[0:])
+	e.key.Ino = binary.BigEndian.Uint64(b[8:])
+	e.key.Size = int64(binary.BigEndian.Uint64(b[16:]))
+	e.key.Mtime = int64(binary.BigEndian.Uint64(b[24:]))
+	e.key.Ctime = int64(binary.BigEndian.Uint64(b[32:]))
+	copy(e.digest[:], b[40:])
+	return e, true
+}
+
+// Get returns the digest of the file with key k, if it is cached and its
+// metadata hasn't changed.
+func (c *Cache) Get(k Key) ([32]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.m[id{k.Dev, k.Ino}]
+	if !ok || e.key != k {
+		return [32]byte{}, false
+	}
+	return e.digest, true
+}
+
+// Put records the digest of the file with key k, replacing any entry for
+// the same file.
+func (c *Cache) Put(k Key, digest [32]byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := entry{k, digest}
+	if old, ok := c.m[id{k.Dev, k.Ino}]; ok && old == e {
+		return nil
+	}
+	if _, err := c.f.Write(encode(e)); err != nil {
+		return err
+	}
+	c.m[id{k.Dev, k.Ino}] = e
+	c.records++
+	if c.wasteful() {
+		return c.compact()
+	}
+	return nil
+}
+
+// wasteful reports whether the log should be compacted: whether more than
+// minDead of its records are dead, and they outnumber the live ones.
+func (c *Cache) wasteful() bool {
+	return c.records-len(c.m) > minDead && c.records > 2*len(c.m)
+}
+
+// Len returns the number of files in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.m)
+}
+
+// Compact rewrites the log with only the live entries, replacing the file
+// atomically.
+func (c *Cache) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.compact()
+}
+
+func (c *Cache) compact() error {
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails once renamed
+	w := bufio.NewWriter(tmp)
+	w.WriteString(magic)
+	for _, e := range c.m {
+		w.Write(encode(e))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Chmod(0644)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	tmp.Close()
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(c.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	c.f.Close()
+	c.f = f
+	c.records = len(c.m)
+	return nil
+}
+
+// Close closes the log.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.f.Close()
+}
@@ -0,0 +1,163 @@
+package filecache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/filecache"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "filecache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCache(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache")
+
+	c, err := filecache.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := filecache.Key{Dev: 1, Ino: 2, Size: 3, Mtime: 4, Ctime: 5}
+	sum := sha2.Sha256([]byte("abc"))
+	if _, ok := c.Get(k); ok {
+		t.Errorf("Get from an empty cache succeeded")
+	}
+	c.Put(k, sum)
+	k2 := filecache.Key{Dev: 1, Ino: 3, Size: 3, Mtime: 4, Ctime: 5}
+	c.Put(k2, sha2.Sha256(nil))
+	c.Close()
+
+	// reopened
+	c, err = filecache.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := c.Get(k); !ok || got != sum {
+		t.Errorf("Get after reopening => %x, %v, want %x", got, ok, sum)
+	}
+
+	// any change of metadata is a miss, and a new entry replaces the old one
+	changed := k
+	changed.Ctime++
+	if _, ok := c.Get(changed); ok {
+		t.Errorf("Get with a changed ctime succeeded")
+	}
+	c.Put(changed, sha2.Sha256([]byte("abcd")))
+	if _, ok := c.Get(k); ok {
+		t.Errorf("Get of a replaced entry succeeded")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() => %d, want 2", c.Len())
+	}
+
+	if err := c.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	c.Put(k, sum)
+	c.Close()
+	fi, _ := os.Stat(path)
+	// header and three records
+	if fi.Size() != 8+3*76 {
+		t.Errorf("cache file of %d bytes after compaction, want %d", fi.Size(), 8+3*76)
+	}
+	c, _ = filecache.Open(path)
+	if got, ok := c.Get(k); !ok || got != sum || c.Len() != 2 {
+		t.Errorf("Get after compaction => %x, %v, %d entries", got, ok, c.Len())
+	}
+	c.Close()
+}
+
+func TestCacheGrowth(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache")
+	c, err := filecache.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// a file that keeps changing, as in a long-running daemon
+	k := filecache.Key{Dev: 1, Ino: 2}
+	for i := 0; i < 5000; i++ {
+		k.Mtime = int64(i)
+		if err := c.Put(k, sha2.Sha256([]byte{byte(i)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fi, _ := os.Stat(path); fi.Size() > 8+2000*76 {
+		t.Errorf("cache file of %d bytes holding one entry, not compacted", fi.Size())
+	}
+	if got, ok := c.Get(k); !ok || got != sha2.Sha256([]byte{byte(4999 % 256)}) {
+		t.Errorf("Get after compactions => %x, %v", got, ok)
+	}
+}
+
+func TestCacheTorn(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache")
+
+	c, _ := filecache.Open(path)
+	k := filecache.Key{Dev: 1, Ino: 2}
+	c.Put(k, sha2.Sha256(nil))
+	c.Close()
+
+	// half a record, as a crash would leave
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write(make([]byte, 40))
+	f.Close()
+
+	c, err := filecache.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(k); !ok {
+		t.Errorf("entry before a torn record lost")
+	}
+	k2 := filecache.Key{Dev: 1, Ino: 3}
+	c.Put(k2, sha2.Sha256(nil))
+	c.Close()
+	c, _ = filecache.Open(path)
+	if _, ok := c.Get(k2); !ok {
+		t.Errorf("entry written after a torn record lost")
+	}
+	c.Close()
+
+	ioutil.WriteFile(path, []byte("not a cache"), 0644)
+	if _, err := filecache.Open(path); err == nil {
+		t.Errorf("Open of a file that isn't a cache succeeded")
+	}
+}
+
+func TestKeyOf(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "f")
+	ioutil.WriteFile(path, []byte("abc"), 0644)
+	fi, _ := os.Stat(path)
+	k, ok := filecache.KeyOf(fi)
+	if !ok {
+		t.Skip("no file keys on this platform")
+	}
+	if k.Size != 3 || k.Ino == 0 || k.Mtime != fi.ModTime().UnixNano() {
+		t.Errorf("KeyOf() => %+v", k)
+	}
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(path, later, later)
+	fi, _ = os.Stat(path)
+	if k2, _ := filecache.KeyOf(fi); k2 == k {
+		t.Errorf("KeyOf() unchanged after a change of mtime")
+	}
+}
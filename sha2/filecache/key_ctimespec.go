@@ -0,0 +1,24 @@
+//go:build darwin || ios || freebsd || netbsd
+
+package filecache
+
+import (
+	"os"
+	"syscall"
+)
+
+// KeyOf returns the key of a file from its metadata, or false if the
+// platform doesn't provide it.
+func KeyOf(fi os.FileInfo) (Key, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return Key{}, false
+	}
+	return Key{
+		Dev:   uint64(st.Dev),
+		Ino:   uint64(st.Ino),
+		Size:  fi.Size(),
+		Mtime: fi.ModTime().UnixNano(),
+		Ctime: st.Ctimespec.Nano(),
+	}, true
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || openbsd || solaris || illumos || dragonfly || darwin || ios || freebsd || netbsd)
+
+package filecache
+
+import "os"
+
+// KeyOf returns false: without inode numbers and change times there is no
+// safe key, so nothing is cached.
+func KeyOf(fi os.FileInfo) (Key, bool) {
+	return Key{}, false
+}
@@ -0,0 +1,365 @@
+// Package httpdigest computes and checks the SHA-256 digest of HTTP message
+// bodies, in the Content-Digest header of RFC 9530:
+//
+//	Content-Digest: sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:
+//
+// and the older Digest header of RFC 3230:
+//
+//	Digest: SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=
+//
+// Bodies are hashed as they stream, never buffered.  When the digest of an
+// outgoing body can't be known before it is sent, it goes in a trailer.
+package httpdigest
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// Header names
+const (
+	ContentDigest = "Content-Digest"
+	LegacyDigest  = "Digest"
+)
+
+var (
+	// ErrMalformed is returned for a digest header that can't be parsed.
+	ErrMalformed = errors.New("httpdigest: malformed digest header")
+
+	// ErrMissingTrailer is returned at the end of a body whose digest was
+	// announced as a trailer but never sent.
+	ErrMissingTrailer = errors.New("httpdigest: digest trailer missing")
+)
+
+// Format returns the Content-Digest field value for sum.
+func Format(sum [32]byte) string {
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// FormatLegacy returns the Digest field value for sum.
+func FormatLegacy(sum [32]byte) string {
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Parse returns the SHA-256 digest in h, from Content-Digest if present, or
+// else from Digest.  ok is false if neither holds a SHA-256 digest; other
+// algorithms are ignored.
+func Parse(h http.Header) (sum [32]byte, ok bool, err error) {
+	if v := h.Values(ContentDigest); len(v) > 0 {
+		return parseContentDigest(strings.Join(v, ","))
+	}
+	if v := h.Values(LegacyDigest); len(v) > 0 {
+		return parseLegacy(strings.Join(v, ","))
+	}
+	return sum, false, nil
+}
+
+// parseContentDigest parses a structured field dictionary of byte sequences,
+// e.g. "sha-512=:...:, sha-256=:...:".  Base64 has no commas, so splitting on
+// them is enough.
+func parseContentDigest(v string) (sum [32]byte, ok bool, err error) {
+	for _, member := range strings.Split(v, ",") {
+		member = strings.TrimSpace(member)
+		if i := strings.IndexByte(member, ';'); i >= 0 {
+			member = member[:i] // parameters
+		}
+		eq := strings.IndexByte(member, '=')
+		if eq < 0 {
+			continue // a boolean member
+		}
+		if member[:eq] != "sha-256" {
+			continue
+		}
+		val := member[eq+1:]
+		if len(val) < 2 || val[0] != ':' || val[len(val)-1] != ':' {
+			return sum, false, ErrMalformed
+		}
+		if err := decode(&sum, val[1:len(val)-1]); err != nil {
+			return sum, false, err
+		}
+		ok = true
+	}
+	return sum, ok, nil
+}
+
+// parseLegacy parses a list of algorithm=value pairs, the algorithm names
+// being case insensitive.
+func parseLegacy(v string) (sum [32]byte, ok bool, err error) {
+	for _, member := range strings.Split(v, ",") {
+		member = strings.TrimSpace(member)
+		eq := strings.IndexByte(member, '=')
+		if eq < 0 {
+			return sum, false, ErrMalformed
+		}
+		if !strings.EqualFold(member[:eq], "sha-256") {
+			continue
+		}
+		if err := decode(&sum, member[eq+1:]); err != nil {
+			return sum, false, err
+		}
+		ok = true
+	}
+	return sum, ok, nil
+}
+
+func decode(sum *[32]byte, s string) error {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != len(sum) {
+		return ErrMalformed
+	}
+	copy(sum[:], b)
+	return nil
+}
+
+// announced reports whether the digest is declared as a trailer.
+func announced(trailer http.Header) bool {
+	_, c := trailer[ContentDigest]
+	_, l := trailer[LegacyDigest]
+	return c || l
+}
+
+// verifyingBody hashes a body as it is read and checks the digest at the end,
+// returning sha2.ErrDigestMismatch instead of io.EOF if it is wrong.  The
+// result comes from a read of its own, never with data, so a reader that
+// stops at the end of what it wants, like json.Decoder, doesn't get it.
+type verifyingBody struct {
+	io.ReadCloser
+	d    *sha2.Digest
+	want func() (sum [32]byte, ok bool, err error) // called at the end
+	err  error
+	seen bool // err has been returned
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		b.seen = true
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.d.Write(p[:n])
+	if err != io.EOF {
+		return n, err
+	}
+	sum, ok, werr := b.want()
+	switch {
+	case werr != nil:
+		b.err = werr
+	case !ok:
+		b.err = ErrMissingTrailer
+	case b.d.Sum256() != sum:
+		b.err = sha2.ErrDigestMismatch
+	default:
+		b.err = io.EOF
+	}
+	if n > 0 {
+		return n, nil
+	}
+	b.seen = true
+	return 0, b.err
+}
+
+// verify wraps body to check it against the digest in header or, if it is
+// announced there, in trailer once the body has been read.  It returns body
+// unchanged if there is no digest to check.
+func verify(body io.ReadCloser, header, trailer http.Header) (io.ReadCloser, error) {
+	sum, ok, err := Parse(header)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return &verifyingBody{ReadCloser: body, d: sha2.New(), want: func() ([32]byte, bool, error) {
+			return sum, true, nil
+		}}, nil
+	}
+	if announced(trailer) {
+		return &verifyingBody{ReadCloser: body, d: sha2.New(), want: func() ([32]byte, bool, error) {
+			return Parse(trailer)
+		}}, nil
+	}
+	return body, nil
+}
+
+// Verify returns middleware that checks the digest of each request body that
+// has one, in a header or a trailer, as next reads it.  A body that doesn't
+// match fails its last read with sha2.ErrDigestMismatch instead of io.EOF,
+// so next must read the body to the end before acting on it: a decoder such
+// as json.Decoder may stop short of EOF and never see the error.  Requests
+// with a malformed digest header are refused with 400 Bad Request.
+//
+// Whatever next leaves unread is read and checked after it returns.  If the
+// digest is wrong and next has written no response, the reply is 400 Bad
+// Request; if it has, the response is aborted, so the client doesn't take it
+// for a success.
+func Verify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := verify(r.Body, r.Header, r.Trailer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		vb, ok := body.(*verifyingBody)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// keep next from closing the body, so what it leaves can be checked;
+		// the server closes it
+		r.Body = ioutil.NopCloser(vb)
+		tw := &trackingWriter{ResponseWriter: w}
+		next.ServeHTTP(tw, r)
+
+		if vb.seen {
+			return // next read to the end, and had the error if there was one
+		}
+		io.Copy(ioutil.Discard, vb)
+		if vb.err == nil || vb.err == io.EOF {
+			return
+		}
+		if tw.wrote {
+			panic(http.ErrAbortHandler)
+		}
+		http.Error(w, vb.err.Error(), http.StatusBadRequest)
+	})
+}
+
+// trackingWriter notes whether a handler has started its response.
+type trackingWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *trackingWriter) WriteHeader(code int) {
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, if the underlying writer does.
+func (w *trackingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wrote = true
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Transport is an http.RoundTripper that adds a Content-Digest, and if
+// Legacy is set a Digest, to each request with a body, and checks the
+// digest of each response body that has one as it is read.
+//
+// A body that can be read again through Request.GetBody, as for bytes,
+// strings and buffers, is hashed first and the digest sent as a header.
+// Any other body is hashed as it is sent, with the digest in a trailer.
+type Transport struct {
+	Base   http.RoundTripper // http.DefaultTransport if nil
+	Legacy bool
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		req, err = t.addDigest(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// a body decompressed by the transport no longer matches its digest
+	if req.Method != http.MethodHead && !resp.Uncompressed {
+		body, err := verify(resp.Body, resp.Header, resp.Trailer)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		resp.Body = body
+	}
+	return resp, nil
+}
+
+// addDigest returns a copy of req that sends the digest of its body.
+func (t *Transport) addDigest(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	r.Header.Del(ContentDigest)
+	r.Header.Del(LegacyDigest)
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		sum, _, err := sha2.SumReader(req.Context(), body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+		t.set(r.Header, sum)
+		return r, nil
+	}
+
+	// sent chunked, with the digest filled in when the body is done
+	r.ContentLength = -1
+	r.Trailer = http.Header{}
+	for k, v := range req.Trailer {
+		r.Trailer[k] = v
+	}
+	r.Trailer[ContentDigest] = nil
+	if t.Legacy {
+		r.Trailer[LegacyDigest] = nil
+	}
+	r.Body = &trailerBody{ReadCloser: req.Body, w: sha2.NewHashingWriter(ioutil.Discard), t: t, trailer: r.Trailer}
+	return r, nil
+}
+
+func (t *Transport) set(h http.Header, sum [32]byte) {
+	h.Set(ContentDigest, Format(sum))
+	if t.Legacy {
+		h.Set(LegacyDigest, FormatLegacy(sum))
+	}
+}
+
+// trailerBody hashes a request body as it is sent and sets the digest
+// trailer at the end.
+type trailerBody struct {
+	io.ReadCloser
+	w       *sha2.HashingWriter
+	t       *Transport
+	trailer http.Header
+}
+
+func (b *trailerBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.w.Write(p[:n])
+	if err == io.EOF {
+		b.t.set(b.trailer, b.w.Sum256())
+	}
+	return n, err
+}
@@ -0,0 +1,221 @@
+package httpdigest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/httpdigest"
+)
+
+// the example in RFC 9530 appendix B
+const (
+	hello       = `{"hello": "world"}`
+	helloDigest = "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:"
+)
+
+func TestFormat(t *testing.T) {
+	sum := sha2.Sha256([]byte(hello))
+	if got := httpdigest.Format(sum); got != helloDigest {
+		t.Errorf("httpdigest.Format() => %q, want %q", got, helloDigest)
+	}
+	if got, want := httpdigest.FormatLegacy(sum), "SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE="; got != want {
+		t.Errorf("httpdigest.FormatLegacy() => %q, want %q", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	sum := sha2.Sha256([]byte(hello))
+	for _, tc := range []struct {
+		name, value string
+		ok          bool
+		err         error
+	}{
+		{httpdigest.ContentDigest, helloDigest, true, nil},
+		{httpdigest.ContentDigest, "sha-512=:YWJj:, " + helloDigest + ";p=1", true, nil},
+		{httpdigest.ContentDigest, "sha-512=:YWJj:", false, nil},
+		{httpdigest.ContentDigest, "sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=", false, httpdigest.ErrMalformed},
+		{httpdigest.ContentDigest, "sha-256=:YWJj:", false, httpdigest.ErrMalformed},
+		{httpdigest.LegacyDigest, "md5=HUXZLQLMuI/KZ5KDcJPcOA==, sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=", true, nil},
+		{httpdigest.LegacyDigest, "SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=", true, nil},
+		{httpdigest.LegacyDigest, "SHA-256", false, httpdigest.ErrMalformed},
+	} {
+		h := http.Header{}
+		h.Set(tc.name, tc.value)
+		got, ok, err := httpdigest.Parse(h)
+		if ok != tc.ok || err != tc.err || ok && got != sum {
+			t.Errorf("httpdigest.Parse(%s: %s) => %x, %v, %v, want ok %v, error %v", tc.name, tc.value, got, ok, err, tc.ok, tc.err)
+		}
+	}
+}
+
+// server returns a server that checks request bodies and replies with the
+// length read, or 400 and the error
+func server() *httptest.Server {
+	return httptest.NewServer(httpdigest.Verify(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set(httpdigest.ContentDigest, httpdigest.Format(sha2.Sha256(b)))
+		w.Write(b)
+	})))
+}
+
+func TestRoundTrip(t *testing.T) {
+	srv := server()
+	defer srv.Close()
+	client := &http.Client{Transport: &httpdigest.Transport{Legacy: true}}
+
+	for _, body := range []io.Reader{
+		strings.NewReader(hello),                   // digest in a header
+		ioutil.NopCloser(strings.NewReader(hello)), // digest in a trailer
+	} {
+		resp, err := client.Post(srv.URL, "application/json", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK || string(b) != hello {
+			t.Errorf("POST %T => %s %q, %v", body, resp.Status, b, err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	srv := server()
+	defer srv.Close()
+
+	post := func(header, value string, trailer bool) (int, string) {
+		var body io.Reader = strings.NewReader(hello)
+		if trailer {
+			body = ioutil.NopCloser(body)
+		}
+		req, _ := http.NewRequest("POST", srv.URL, body)
+		if trailer {
+			req.Trailer = http.Header{header: []string{value}}
+		} else {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, strings.TrimSpace(string(b))
+	}
+
+	wrong := httpdigest.Format(sha2.Sha256([]byte("other")))
+	for _, tc := range []struct {
+		header, value string
+		trailer       bool
+		status        int
+		body          string
+	}{
+		{httpdigest.ContentDigest, helloDigest, false, 200, hello},
+		{httpdigest.ContentDigest, helloDigest, true, 200, hello},
+		{httpdigest.ContentDigest, wrong, false, 400, sha2.ErrDigestMismatch.Error()},
+		{httpdigest.ContentDigest, wrong, true, 400, sha2.ErrDigestMismatch.Error()},
+		{httpdigest.LegacyDigest, "SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=", false, 200, hello},
+		{httpdigest.ContentDigest, "sha-256=:abc", false, 400, httpdigest.ErrMalformed.Error()},
+	} {
+		status, body := post(tc.header, tc.value, tc.trailer)
+		if status != tc.status || body != tc.body {
+			t.Errorf("POST with %s: %s (trailer %v) => %d %q, want %d %q", tc.header, tc.value, tc.trailer, status, body, tc.status, tc.body)
+		}
+	}
+}
+
+// TestUnread checks bodies a handler stops reading before the end, as a
+// json.Decoder does.
+func TestUnread(t *testing.T) {
+	for _, reply := range []bool{false, true} {
+		srv := httptest.NewServer(httpdigest.Verify(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var v map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if reply {
+				w.Write([]byte(v["hello"]))
+			}
+		})))
+
+		// a decoder stops before the newline
+		good := httpdigest.Format(sha2.Sha256([]byte(hello + "\n")))
+		for _, digest := range []string{good, helloDigest} {
+			req, _ := http.NewRequest("POST", srv.URL, strings.NewReader(hello+"\n"))
+			req.Header.Set(httpdigest.ContentDigest, digest)
+			resp, err := http.DefaultClient.Do(req)
+			var status int
+			var b []byte
+			if err == nil {
+				status = resp.StatusCode
+				b, err = ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			body := strings.TrimSpace(string(b))
+			switch {
+			case digest == good:
+				if err != nil || status != 200 || reply && body != "world" {
+					t.Errorf("reply %v: POST => %d %q, %v, want 200", reply, status, body, err)
+				}
+			case reply:
+				if err == nil {
+					t.Errorf("reply %v: POST with wrong digest => %d %q, want the response aborted", reply, status, body)
+				}
+			default:
+				if status != 400 || body != sha2.ErrDigestMismatch.Error() {
+					t.Errorf("reply %v: POST with wrong digest => %d %q, %v, want 400", reply, status, body, err)
+				}
+			}
+		}
+		srv.Close()
+	}
+}
+
+func TestResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httpdigest.ContentDigest, helloDigest)
+		io.WriteString(w, r.URL.Query().Get("body"))
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: &httpdigest.Transport{}}
+
+	for body, want := range map[string]error{hello: nil, "tampered": sha2.ErrDigestMismatch} {
+		resp, err := client.Get(srv.URL + "?body=" + strings.Replace(body, " ", "%20", -1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if !errors.Is(err, want) {
+			t.Errorf("GET %q with the digest of %q => %v, want %v", body, hello, err, want)
+		}
+	}
+}
+
+func TestTransportKeepsRequest(t *testing.T) {
+	srv := server()
+	defer srv.Close()
+	client := &http.Client{Transport: &httpdigest.Transport{}}
+	req, _ := http.NewRequest("PUT", srv.URL, bytes.NewReader([]byte(hello)))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(req.Header) != 0 {
+		t.Errorf("httpdigest.Transport changed the caller's request headers: %v", req.Header)
+	}
+}
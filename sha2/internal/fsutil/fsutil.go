@@ -0,0 +1,32 @@
+// Package fsutil holds the file system helpers that the packages keeping
+// state on disk share: making a rename durable, and locking a file against
+// other processes.
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrLocked is returned by Lock for a file another process, or another
+// Lock in this one, holds.
+var ErrLocked = errors.New("fsutil: file is locked")
+
+// SyncDir syncs the directory holding path, so a rename in it is durable.
+// Windows and Plan 9 can't sync a directory, and the error saying so is
+// ignored there.
+func SyncDir(path string) error {
+	d, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if err != nil && dirSyncUnsupported(err) {
+		err = nil
+	}
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
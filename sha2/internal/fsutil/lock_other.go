@@ -0,0 +1,20 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package fsutil
+
+import "os"
+
+// Lock takes an exclusive lock on the file at path by creating it, without
+// waiting; unlock removes it.  Without flock a process that dies holding the
+// lock leaves the file, which must then be removed by hand.
+func Lock(path string) (unlock func() error, err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return func() error { return os.Remove(path) }, nil
+}
@@ -0,0 +1,9 @@
+//go:build !windows && !plan9
+
+package fsutil
+
+// dirSyncUnsupported reports false: a failure to sync a directory here is
+// a failure to make a rename durable.
+func dirSyncUnsupported(err error) bool {
+	return false
+}
@@ -0,0 +1,9 @@
+package fsutil
+
+import "os"
+
+// dirSyncUnsupported reports whether err is Plan 9 refusing the wstat that
+// syncs a file, which a directory's server may do.
+func dirSyncUnsupported(err error) bool {
+	return os.IsPermission(err)
+}
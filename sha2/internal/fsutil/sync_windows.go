@@ -0,0 +1,12 @@
+package fsutil
+
+import (
+	"errors"
+	"syscall"
+)
+
+// dirSyncUnsupported reports whether err is Windows refusing to flush a
+// directory, which it opens read-only.
+func dirSyncUnsupported(err error) bool {
+	return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+}
@@ -0,0 +1,232 @@
+// Package lamport implements Lamport one-time signatures of 256-bit message
+// digests, with sha2.Sha256 as the hash.
+//
+// A private key is 256 pairs of 32-byte secrets, derived from a seed, and
+// the public key their 512 hashes.  Signing reveals one secret of each pair,
+// chosen by a bit of the digest, and verifying hashes each revealed secret
+// and compares it with the public key.  Each signature reveals half the
+// secrets, so a key signing two different digests lets anyone forge a third;
+// a PrivateKey refuses to sign twice.
+//
+// Keys and signatures are serialized as a 4-byte tag and the raw values:
+//
+//	private key  "lsk1", used flag (1 byte), seed (32 bytes)
+//	used key     "lsk1", used flag, the 512 hashes of the public key
+//	public key   "lpk1", 512 hashes, the pair for bit 0 first
+//	signature    "lsg1", 256 secrets
+package lamport
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// Sizes of the serialized forms.
+const (
+	SeedSize           = 32
+	PrivateKeySize     = 4 + 1 + SeedSize
+	UsedPrivateKeySize = 4 + 1 + 512*32
+	PublicKeySize      = 4 + 512*32
+	SignatureSize      = 4 + 256*32
+)
+
+const (
+	privateTag   = "lsk1"
+	publicTag    = "lpk1"
+	signatureTag = "lsg1"
+)
+
+var (
+	// ErrKeyUsed is returned when signing with a key that has signed before.
+	ErrKeyUsed = errors.New("lamport: one-time key already used")
+
+	// ErrFormat is returned for a key or signature that can't be decoded.
+	ErrFormat = errors.New("lamport: malformed key or signature")
+)
+
+// PrivateKey is a one-time signing key.
+type PrivateKey struct {
+	seed [SeedSize]byte
+	used bool
+	pub  *PublicKey // kept once the seed is gone
+}
+
+// PublicKey verifies the signature made by its private key.
+type PublicKey struct {
+	h [256][2][32]byte
+}
+
+// Signature is the secrets revealed for a digest.
+type Signature struct {
+	s [256][32]byte
+}
+
+// NewKey returns the private key derived from seed.  The same seed always
+// gives the same key, so a seed must not be used again once its key has
+// signed.
+func NewKey(seed [SeedSize]byte) *PrivateKey {
+	return &PrivateKey{seed: seed}
+}
+
+// GenerateKey returns a private key with a seed read from rand, such as
+// crypto/rand.Reader.
+func GenerateKey(rand io.Reader) (*PrivateKey, error) {
+	var seed [SeedSize]byte
+	if _, err := io.ReadFull(rand, seed[:]); err != nil {
+		return nil, err
+	}
+	return NewKey(seed), nil
+}
+
+// secret returns secret b of pair i: SHA-256(seed || i || b), i and b
+// together a 16-bit big-endian number.
+func (k *PrivateKey) secret(i, b int) [32]byte {
+	var m [SeedSize + 2]byte
+	copy(m[:], k.seed[:])
+	binary.BigEndian.PutUint16(m[SeedSize:], uint16(2*i+b))
+	return sha2.Sha256(m[:])
+}
+
+// Used reports whether the key has signed.
+func (k *PrivateKey) Used() bool {
+	return k.used
+}
+
+// Public returns the public key.
+func (k *PrivateKey) Public() *PublicKey {
+	if k.pub != nil {
+		return k.pub
+	}
+	pk := &PublicKey{}
+	for i := range pk.h {
+		for b := 0; b < 2; b++ {
+			s := k.secret(i, b)
+			pk.h[i][b] = sha2.Sha256(s[:])
+		}
+	}
+	return pk
+}
+
+// bit returns bit i of digest, most significant first.
+func bit(digest *[32]byte, i int) int {
+	return int(digest[i/8]>>(7-uint(i%8))) & 1
+}
+
+// Sign signs digest, after which the key can't sign again and its seed is
+// erased.  A key saved with MarshalBinary before signing must be saved again
+// afterwards, or the saved copy could sign a second time.
+func (k *PrivateKey) Sign(digest [32]byte) (*Signature, error) {
+	if k.used {
+		return nil, ErrKeyUsed
+	}
+	k.pub = k.Public()
+	k.used = true
+	sig := &Signature{}
+	for i := range sig.s {
+		sig.s[i] = k.secret(i, bit(&digest, i))
+	}
+	k.seed = [SeedSize]byte{}
+	return sig, nil
+}
+
+// Verify reports whether sig is a signature of digest by pk.
+func Verify(pk *PublicKey, digest [32]byte, sig *Signature) bool {
+	ok := 1
+	for i := range sig.s {
+		h := sha2.Sha256(sig.s[i][:])
+		ok &= subtle.ConstantTimeCompare(h[:], pk.h[i][bit(&digest, i)][:])
+	}
+	return ok == 1
+}
+
+// MarshalBinary encodes the key, including whether it has signed.  A key
+// that has signed has no seed, and its public key is encoded instead, in
+// UsedPrivateKeySize bytes.
+func (k *PrivateKey) MarshalBinary() ([]byte, error) {
+	if k.used {
+		b := make([]byte, 0, UsedPrivateKeySize)
+		b = append(append(b, privateTag...), 1)
+		return k.pub.appendHashes(b), nil
+	}
+	b := make([]byte, 0, PrivateKeySize)
+	b = append(append(b, privateTag...), 0)
+	return append(b, k.seed[:]...), nil
+}
+
+// UnmarshalBinary decodes a key encoded by MarshalBinary.
+func (k *PrivateKey) UnmarshalBinary(b []byte) error {
+	switch {
+	case len(b) == PrivateKeySize && string(b[:4]) == privateTag && b[4] == 0:
+		*k = PrivateKey{}
+		copy(k.seed[:], b[5:])
+	case len(b) == UsedPrivateKeySize && string(b[:4]) == privateTag && b[4] == 1:
+		*k = PrivateKey{used: true, pub: &PublicKey{}}
+		k.pub.setHashes(b[5:])
+	default:
+		return ErrFormat
+	}
+	return nil
+}
+
+// MarshalBinary encodes the key.
+func (pk *PublicKey) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, PublicKeySize)
+	return pk.appendHashes(append(b, publicTag...)), nil
+}
+
+// UnmarshalBinary decodes a key encoded by MarshalBinary.
+func (pk *PublicKey) UnmarshalBinary(b []byte) error {
+	if len(b) != PublicKeySize || string(b[:4]) != publicTag {
+		return ErrFormat
+	}
+	pk.setHashes(b[4:])
+	return nil
+}
+
+// appendHashes appends the 512 hashes to b, the pair for bit 0 first.
+func (pk *PublicKey) appendHashes(b []byte) []byte {
+	for i := range pk.h {
+		b = append(b, pk.h[i][0][:]...)
+		b = append(b, pk.h[i][1][:]...)
+	}
+	return b
+}
+
+// setHashes decodes the hashes written by appendHashes.
+func (pk *PublicKey) setHashes(b []byte) {
+	for i := range pk.h {
+		copy(pk.h[i][0][:], b[64*i:])
+		copy(pk.h[i][1][:], b[64*i+32:])
+	}
+}
+
+// Fingerprint returns the SHA-256 of the encoded key, a short name for it.
+func (pk *PublicKey) Fingerprint() [32]byte {
+	b, _ := pk.MarshalBinary()
+	return sha2.Sha256(b)
+}
+
+// MarshalBinary encodes the signature.
+func (sig *Signature) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, SignatureSize)
+	b = append(b, signatureTag...)
+	for i := range sig.s {
+		b = append(b, sig.s[i][:]...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a signature encoded by MarshalBinary.
+func (sig *Signature) UnmarshalBinary(b []byte) error {
+	if len(b) != SignatureSize || string(b[:4]) != signatureTag {
+		return ErrFormat
+	}
+	for i := range sig.s {
+		copy(sig.s[i][:], b[4+32*i:])
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package lamport_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/lamport"
+)
+
+func TestSign(t *testing.T) {
+	k, err := lamport.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := k.Public()
+	digest := sha2.Sha256([]byte("pay Bob 10"))
+	sig, err := k.Sign(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lamport.Verify(pk, digest, sig) {
+		t.Errorf("Verify(signed digest) => false")
+	}
+
+	// any flipped bit of the digest or the signature fails
+	for i := 0; i < 256; i += 17 {
+		d := digest
+		d[i/8] ^= 1 << uint(i%8)
+		if lamport.Verify(pk, d, sig) {
+			t.Errorf("Verify(digest with bit %d flipped) => true", i)
+		}
+	}
+	b, _ := sig.MarshalBinary()
+	for _, i := range []int{4, 100, len(b) - 1} {
+		bad := append([]byte(nil), b...)
+		bad[i] ^= 0x40
+		var s lamport.Signature
+		if err := s.UnmarshalBinary(bad); err != nil {
+			t.Fatal(err)
+		}
+		if lamport.Verify(pk, digest, &s) {
+			t.Errorf("Verify(signature with byte %d changed) => true", i)
+		}
+	}
+
+	other, _ := lamport.GenerateKey(rand.Reader)
+	if lamport.Verify(other.Public(), digest, sig) {
+		t.Errorf("Verify(another key) => true")
+	}
+}
+
+func TestReuse(t *testing.T) {
+	k := lamport.NewKey([lamport.SeedSize]byte{1, 2, 3})
+	saved, _ := k.MarshalBinary()
+	if _, err := k.Sign(sha2.Sha256([]byte("one"))); err != nil {
+		t.Fatal(err)
+	}
+	if !k.Used() {
+		t.Errorf("Used() => false after signing")
+	}
+	want, _ := lamport.NewKey([lamport.SeedSize]byte{1, 2, 3}).Public().MarshalBinary()
+	if got, _ := k.Public().MarshalBinary(); !bytes.Equal(got, want) {
+		t.Errorf("Public() after signing isn't the key's public key")
+	}
+	if sig, err := k.Sign(sha2.Sha256([]byte("two"))); err != lamport.ErrKeyUsed || sig != nil {
+		t.Errorf("second Sign => %v, %v, want ErrKeyUsed", sig, err)
+	}
+
+	// the used flag and the public key survive serialization
+	used, _ := k.MarshalBinary()
+	var k2 lamport.PrivateKey
+	if err := k2.UnmarshalBinary(used); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k2.Sign(sha2.Sha256([]byte("two"))); err != lamport.ErrKeyUsed {
+		t.Errorf("Sign with a decoded used key => %v, want ErrKeyUsed", err)
+	}
+	if len(used) != lamport.UsedPrivateKeySize {
+		t.Errorf("used key is %d bytes, want %d", len(used), lamport.UsedPrivateKeySize)
+	}
+	if got, _ := k2.Public().MarshalBinary(); !bytes.Equal(got, want) {
+		t.Errorf("Public() of a decoded used key isn't the key's public key")
+	}
+	if again, _ := k2.MarshalBinary(); !bytes.Equal(again, used) {
+		t.Errorf("decoded used key encodes differently")
+	}
+
+	// keys are deterministic in the seed
+	var k3 lamport.PrivateKey
+	if err := k3.UnmarshalBinary(saved); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := k3.Public().MarshalBinary(); !bytes.Equal(a, want) {
+		t.Errorf("keys from the same seed differ")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	k := lamport.NewKey([lamport.SeedSize]byte{9})
+	pk := k.Public()
+	b, _ := pk.MarshalBinary()
+	if len(b) != lamport.PublicKeySize {
+		t.Errorf("public key is %d bytes, want %d", len(b), lamport.PublicKeySize)
+	}
+	var pk2 lamport.PublicKey
+	if err := pk2.UnmarshalBinary(b); err != nil || pk2.Fingerprint() != pk.Fingerprint() {
+		t.Errorf("public key round trip => %v", err)
+	}
+
+	digest := sha2.Sha256(nil)
+	sig, _ := k.Sign(digest)
+	sb, _ := sig.MarshalBinary()
+	var sig2 lamport.Signature
+	if err := sig2.UnmarshalBinary(sb); err != nil || !lamport.Verify(&pk2, digest, &sig2) {
+		t.Errorf("signature round trip => %v", err)
+	}
+
+	for _, bad := range [][]byte{nil, sb[:len(sb)-1], append([]byte("lsg2"), sb[4:]...)} {
+		if err := sig2.UnmarshalBinary(bad); err != lamport.ErrFormat {
+			t.Errorf("UnmarshalBinary(%d bytes) => %v, want ErrFormat", len(bad), err)
+		}
+	}
+	if err := pk2.UnmarshalBinary(sb); err != lamport.ErrFormat {
+		t.Errorf("PublicKey.UnmarshalBinary(signature) => %v, want ErrFormat", err)
+	}
+}
@@ -0,0 +1,48 @@
+// Package lengthext demonstrates the length-extension attack on SHA-256, for
+// security training.
+//
+// SHA-256 is a Merkle–Damgård hash: its digest is the intermediate hash
+// value after the last, padded block.  Anyone who knows H(secret || msg) and
+// the length of secret || msg can therefore restart the hash from the digest
+// and compute
+//
+//	H(secret || msg || glue || ext)
+//
+// for any ext, without knowing secret, where glue is the padding SHA-256
+// added to secret || msg.  A MAC computed as H(key || msg) is forgeable this
+// way; HMAC is not, since its outer hash covers the inner digest with a key.
+package lengthext
+
+import (
+	"encoding/binary"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// State returns the intermediate hash value a digest was read from.
+func State(digest [32]byte) [8]uint32 {
+	var h [8]uint32
+	for j := range h {
+		h[j] = binary.BigEndian.Uint32(digest[j*4:])
+	}
+	return h
+}
+
+// Forge returns the digest of m || glue || ext, given only the digest of a
+// message m of origLen bytes, and the glue: the padding SHA-256 added to m.
+func Forge(digest [32]byte, origLen uint64, ext []byte) (forged [32]byte, glue []byte) {
+	glue = sha2.Padding(origLen)
+	d := sha2.NewFromMidstate(State(digest), origLen+uint64(len(glue)))
+	d.Write(ext)
+	return d.Sum256(), glue
+}
+
+// Extend returns msg || glue || ext, the message whose digest Forge returns
+// when m is an unknown secret of keyLen bytes followed by msg.
+func Extend(msg []byte, keyLen int, ext []byte) []byte {
+	glue := sha2.Padding(uint64(keyLen + len(msg)))
+	b := make([]byte, 0, len(msg)+len(glue)+len(ext))
+	b = append(b, msg...)
+	b = append(b, glue...)
+	return append(b, ext...)
+}
@@ -0,0 +1,92 @@
+package lengthext_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"hash"
+	"testing"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/lengthext"
+)
+
+var key = []byte("do not tell anyone")
+
+// naiveMAC is the forgeable H(key || msg)
+func naiveMAC(msg []byte) [32]byte {
+	d := sha2.New()
+	d.Write(key)
+	d.Write(msg)
+	return d.Sum256()
+}
+
+func hmacSHA256(msg []byte) []byte {
+	m := hmac.New(func() hash.Hash { return sha2.New() }, key)
+	m.Write(msg)
+	return m.Sum(nil)
+}
+
+func TestForge(t *testing.T) {
+	msg := []byte("user=guest&role=reader")
+	ext := []byte("&role=admin")
+
+	// the attacker knows msg, its MAC and the key length, not the key
+	mac := naiveMAC(msg)
+	forged, glue := lengthext.Forge(mac, uint64(len(key)+len(msg)), ext)
+	evil := lengthext.Extend(msg, len(key), ext)
+	if want := append(append(append([]byte(nil), msg...), glue...), ext...); !bytes.Equal(evil, want) {
+		t.Fatalf("Extend => %x, want msg || glue || ext %x", evil, want)
+	}
+	if (len(key)+len(msg)+len(glue))%64 != 0 || glue[0] != 0x80 {
+		t.Errorf("Forge glue %x doesn't pad %d bytes", glue, len(key)+len(msg))
+	}
+	if got := naiveMAC(evil); got != forged {
+		t.Errorf("naive MAC of forged message %x, Forge => %x", got, forged)
+	}
+
+	// the same forgery doesn't pass HMAC
+	if hmac.Equal(hmacSHA256(evil), forged[:]) {
+		t.Errorf("HMAC of forged message matches the forgery")
+	}
+
+	// extending the tag as the outer hash it is, of the padded key and the
+	// inner digest, does give the SHA-256 of that and more, but that isn't
+	// the HMAC of any message: the inner digest can't take the extension
+	tag := hmacSHA256(msg)
+	var d [32]byte
+	copy(d[:], tag)
+	forged, glue = lengthext.Forge(d, 64+32, ext)
+	ipad, opad := make([]byte, 64), make([]byte, 64)
+	copy(ipad, key)
+	copy(opad, key)
+	for i := range ipad {
+		ipad[i] ^= 0x36
+		opad[i] ^= 0x5c
+	}
+	innerSum := sha2.Sha256(append(ipad, msg...))
+	outer := append(append(append(opad, innerSum[:]...), glue...), ext...)
+	if got := sha2.Sha256(outer); got != forged {
+		t.Errorf("SHA-256 of the extended outer message %x, Forge => %x", got, forged)
+	}
+	for _, m := range [][]byte{
+		lengthext.Extend(msg, len(key), ext),
+		append(append(append([]byte(nil), msg...), glue...), ext...),
+	} {
+		if hmac.Equal(hmacSHA256(m), forged[:]) {
+			t.Errorf("HMAC of %q matches the tag extended as the outer hash", m)
+		}
+	}
+}
+
+func TestForgeLengths(t *testing.T) {
+	// the glue can spill into another block, and ext can span several
+	for n := 0; n < 130; n++ {
+		m := bytes.Repeat([]byte{'m'}, n)
+		ext := bytes.Repeat([]byte{'x'}, n%70)
+		forged, glue := lengthext.Forge(sha2.Sha256(m), uint64(n), ext)
+		full := append(append(append([]byte(nil), m...), glue...), ext...)
+		if got := sha2.Sha256(full); got != forged {
+			t.Errorf("Forge(%d bytes, %d ext) => %x, want %x", n, len(ext), forged, got)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Package merkle builds the Merkle hash trees of RFC 6962 (Certificate
+// Transparency) on SHA-256, with inclusion and consistency proofs.
+//
+// A leaf is hashed as SHA-256(0x00 || leaf) and an interior node as
+// SHA-256(0x01 || left || right), so a leaf can't pass for a node.  A tree
+// of n leaves is split after the largest power of two less than n, which
+// lets it grow by appending.  The proofs are verified with the algorithms of
+// RFC 9162, section 2.1.
+package merkle
+
+import (
+	"errors"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+var (
+	// ErrRange is returned for a leaf index or tree size out of range.
+	ErrRange = errors.New("merkle: index or size out of range")
+
+	// ErrProof is returned when a proof doesn't verify.
+	ErrProof = errors.New("merkle: invalid proof")
+)
+
+// LeafHash returns SHA-256(0x00 || leaf).
+func LeafHash(leaf []byte) [32]byte {
+	d := sha2.New()
+	d.Write([]byte{0})
+	d.Write(leaf)
+	return d.Sum256()
+}
+
+// NodeHash returns SHA-256(0x01 || left || right).
+func NodeHash(left, right [32]byte) [32]byte {
+	d := sha2.New()
+	d.Write([]byte{1})
+	d.Write(left[:])
+	d.Write(right[:])
+	return d.Sum256()
+}
+
+// EmptyRoot is the root of the tree with no leaves, SHA-256 of nothing.
+func EmptyRoot() [32]byte {
+	return sha2.Sha256(nil)
+}
+
+// Tree is a Merkle tree, holding the hash of each leaf.
+type Tree struct {
+	leaves [][32]byte
+}
+
+// New returns an empty tree.
+func New() *Tree {
+	return &Tree{}
+}
+
+// Append adds a leaf, returning its index.
+func (t *Tree) Append(leaf []byte) int {
+	return t.AppendHash(LeafHash(leaf))
+}
+
+// AppendHash adds a leaf by its leaf hash, returning its index.
+func (t *Tree) AppendHash(h [32]byte) int {
+	t.leaves = append(t.leaves, h)
+	return len(t.leaves) - 1
+}
+
+// Size returns the number of leaves.
+func (t *Tree) Size() int {
+	return len(t.leaves)
+}
+
+// LeafHash returns the hash of leaf i.
+func (t *Tree) LeafHash(i int) [32]byte {
+	return t.leaves[i]
+}
+
+// Root returns the root hash of the whole tree.
+func (t *Tree) Root() [32]byte {
+	return mth(t.leaves)
+}
+
+// RootAt returns the root hash the tree had when it held size leaves.
+func (t *Tree) RootAt(size int) ([32]byte, error) {
+	if size < 0 || size > len(t.leaves) {
+		return [32]byte{}, ErrRange
+	}
+	return mth(t.leaves[:size]), nil
+}
+
+// Root returns the root hash of a tree of leaves.
+func Root(leaves [][]byte) [32]byte {
+	t := New()
+	for _, l := range leaves {
+		t.Append(l)
+	}
+	return t.Root()
+}
+
+// split returns the largest power of two less than n, n > 1.
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// mth is the Merkle Tree Hash of RFC 6962, section 2.1.
+func mth(d [][32]byte) [32]byte {
+	switch len(d) {
+	case 0:
+		return EmptyRoot()
+	case 1:
+		return d[0]
+	}
+	k := split(len(d))
+	return NodeHash(mth(d[:k]), mth(d[k:]))
+}
+
+// InclusionProof returns the audit path of leaf index in the tree of the
+// first size leaves, from the leaf up.
+func (t *Tree) InclusionProof(index, size int) ([][32]byte, error) {
+	if size > len(t.leaves) || index < 0 || index >= size {
+		return nil, ErrRange
+	}
+	return path(index, t.leaves[:size]), nil
+}
+
+// path is PATH(m, D[n]) of RFC 6962, section 2.1.1.
+func path(m int, d [][32]byte) [][32]byte {
+	if len(d) <= 1 {
+		return nil
+	}
+	k := split(len(d))
+	if m < k {
+		return append(path(m, d[:k]), mth(d[k:]))
+	}
+	return append(path(m-k, d[k:]), mth(d[:k]))
+}
+
+// ConsistencyProof returns the proof that the tree of the first size2
+// leaves extends the tree of the first size1.
+func (t *Tree) ConsistencyProof(size1, size2 int) ([][32]byte, error) {
+	if size1 < 0 || size1 > size2 || size2 > len(t.leaves) {
+		return nil, ErrRange
+	}
+	if size1 == 0 || size1 == size2 {
+		return nil, nil
+	}
+	return subproof(size1, t.leaves[:size2], true), nil
+}
+
+// subproof is SUBPROOF(m, D[n], b) of RFC 6962, section 2.1.2.
+func subproof(m int, d [][32]byte, b bool) [][32]byte {
+	if m == len(d) {
+		if b {
+			return nil
+		}
+		return [][32]byte{mth(d)}
+	}
+	k := split(len(d))
+	if m <= k {
+		return append(subproof(m, d[:k], b), mth(d[k:]))
+	}
+	return append(subproof(m-k, d[k:], false), mth(d[:k]))
+}
+
+// VerifyInclusion checks that proof shows the leaf with hash leafHash is at
+// index in the tree of size leaves with the given root.
+func VerifyInclusion(leafHash [32]byte, index, size uint64, proof [][32]byte, root [32]byte) error {
+	if index >= size {
+		return ErrRange
+	}
+	fn, sn := index, size-1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return ErrProof
+		}
+		if fn&1 == 1 || fn == sn {
+			r = NodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = NodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || r != root {
+		return ErrProof
+	}
+	return nil
+}
+
+// VerifyConsistency checks that proof shows the tree of size2 leaves with
+// root2 extends the tree of size1 leaves with root1.  An empty old tree must
+// have the empty root.
+func VerifyConsistency(size1, size2 uint64, proof [][32]byte, root1, root2 [32]byte) error {
+	switch {
+	case size1 > size2:
+		return ErrRange
+	case size1 == 0 && root1 != EmptyRoot():
+		return ErrProof
+	case size1 == size2:
+		if len(proof) != 0 || root1 != root2 {
+			return ErrProof
+		}
+		return nil
+	case size1 == 0:
+		// every tree extends the empty one
+		if len(proof) != 0 {
+			return ErrProof
+		}
+		return nil
+	case len(proof) == 0:
+		return ErrProof
+	}
+
+	if size1&(size1-1) == 0 {
+		// the old tree is a complete subtree, whose root the proof omits
+		proof = append([][32]byte{root1}, proof...)
+	}
+	fn, sn := size1-1, size2-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return ErrProof
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = NodeHash(c, fr)
+			sr = NodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = NodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 || fr != root1 || sr != root2 {
+		return ErrProof
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package merkle_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/jwatson0/go/gosha256/sha2/merkle"
+)
+
+// the leaves and roots of the RFC 6962 test vectors used by Certificate
+// Transparency implementations
+var leaves = []string{
+	"",
+	"00",
+	"10",
+	"2021",
+	"3031",
+	"40414243",
+	"5051525354555657",
+	"606162636465666768696a6b6c6d6e6f",
+}
+
+var roots = []string{
+	"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", // empty
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+var inclusionProofs = []struct {
+	index, size int
+	proof       []string
+}{
+	{0, 1, nil},
+	{0, 8, []string{
+		"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+	}},
+	{5, 8, []string{
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	}},
+	{2, 3, []string{
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	}},
+	{1, 5, []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+	}},
+}
+
+var consistencyProofs = []struct {
+	size1, size2 int
+	proof        []string
+}{
+	{1, 1, nil},
+	{1, 8, []string{
+		"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+	}},
+	{6, 8, []string{
+		"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+		"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	}},
+	{2, 5, []string{
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+	}},
+	{3, 7, []string{
+		"0298d122906dcfc10892cb53a73992fc5b9f493ea4c9badb27b791b4127a7fe7",
+		"07506a85fd9dd2f120eb694f86011e5bb4662e5c415a62917033d4a9624487e7",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"837dbb152e9b079010717e84e865da4ebc0fa198a806d59d31bf15accef22d0e",
+	}},
+}
+
+func h(s string) [32]byte {
+	var x [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 32 {
+		panic("bad hash " + s)
+	}
+	copy(x[:], b)
+	return x
+}
+
+func hashes(ss []string) [][32]byte {
+	var p [][32]byte
+	for _, s := range ss {
+		p = append(p, h(s))
+	}
+	return p
+}
+
+func tree() *merkle.Tree {
+	t := merkle.New()
+	for _, l := range leaves {
+		b, _ := hex.DecodeString(l)
+		t.Append(b)
+	}
+	return t
+}
+
+func equal(a, b [][32]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRoots(t *testing.T) {
+	tr := tree()
+	for n, want := range roots {
+		got, err := tr.RootAt(n)
+		if err != nil || got != h(want) {
+			t.Errorf("RootAt(%d) => %x, %v, want %s", n, got, err, want)
+		}
+	}
+	if tr.Root() != h(roots[8]) {
+		t.Errorf("Root() => %x, want %s", tr.Root(), roots[8])
+	}
+	var raw [][]byte
+	for _, l := range leaves[:3] {
+		b, _ := hex.DecodeString(l)
+		raw = append(raw, b)
+	}
+	if got := merkle.Root(raw); got != h(roots[3]) {
+		t.Errorf("merkle.Root(3 leaves) => %x, want %s", got, roots[3])
+	}
+}
+
+func TestInclusion(t *testing.T) {
+	tr := tree()
+	for _, tc := range inclusionProofs {
+		want := hashes(tc.proof)
+		got, err := tr.InclusionProof(tc.index, tc.size)
+		if err != nil || !equal(got, want) {
+			t.Errorf("InclusionProof(%d, %d) => %x, %v, want %s", tc.index, tc.size, got, err, tc.proof)
+		}
+		if err := merkle.VerifyInclusion(tr.LeafHash(tc.index), uint64(tc.index), uint64(tc.size), want, h(roots[tc.size])); err != nil {
+			t.Errorf("VerifyInclusion(%d, %d) => %v", tc.index, tc.size, err)
+		}
+	}
+
+	// every proof of every tree verifies, and none verifies once changed
+	for size := 1; size <= len(leaves); size++ {
+		root := h(roots[size])
+		for i := 0; i < size; i++ {
+			p, _ := tr.InclusionProof(i, size)
+			if err := merkle.VerifyInclusion(tr.LeafHash(i), uint64(i), uint64(size), p, root); err != nil {
+				t.Errorf("VerifyInclusion(%d, %d) => %v", i, size, err)
+			}
+			if err := merkle.VerifyInclusion(tr.LeafHash(i), uint64(i), uint64(size), p, h(roots[size-1])); err == nil {
+				t.Errorf("VerifyInclusion(%d, %d) with the wrong root succeeded", i, size)
+			}
+			if i > 0 {
+				if err := merkle.VerifyInclusion(tr.LeafHash(i), uint64(i-1), uint64(size), p, root); err == nil {
+					t.Errorf("VerifyInclusion(%d, %d) at the wrong index succeeded", i, size)
+				}
+			}
+			for j := range p {
+				q := append([][32]byte(nil), p...)
+				q[j][0] ^= 1
+				if err := merkle.VerifyInclusion(tr.LeafHash(i), uint64(i), uint64(size), q, root); err == nil {
+					t.Errorf("VerifyInclusion(%d, %d) with proof hash %d changed succeeded", i, size, j)
+				}
+			}
+			if len(p) > 0 {
+				if err := merkle.VerifyInclusion(tr.LeafHash(i), uint64(i), uint64(size), p[:len(p)-1], root); err == nil {
+					t.Errorf("VerifyInclusion(%d, %d) with a short proof succeeded", i, size)
+				}
+			}
+		}
+	}
+	if _, err := tr.InclusionProof(8, 8); err != merkle.ErrRange {
+		t.Errorf("InclusionProof(8, 8) => %v, want %v", err, merkle.ErrRange)
+	}
+}
+
+func TestConsistency(t *testing.T) {
+	tr := tree()
+	for _, tc := range consistencyProofs {
+		want := hashes(tc.proof)
+		got, err := tr.ConsistencyProof(tc.size1, tc.size2)
+		if err != nil || !equal(got, want) {
+			t.Errorf("ConsistencyProof(%d, %d) => %x, %v, want %s", tc.size1, tc.size2, got, err, tc.proof)
+		}
+	}
+
+	for size2 := 0; size2 <= len(leaves); size2++ {
+		for size1 := 0; size1 <= size2; size1++ {
+			p, err := tr.ConsistencyProof(size1, size2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r1, r2 := h(roots[size1]), h(roots[size2])
+			if err := merkle.VerifyConsistency(uint64(size1), uint64(size2), p, r1, r2); err != nil {
+				t.Errorf("VerifyConsistency(%d, %d) => %v", size1, size2, err)
+			}
+			if size1 == 0 {
+				// any old root but the empty one is wrong
+				if err := merkle.VerifyConsistency(0, uint64(size2), p, h(roots[1]), r2); err == nil {
+					t.Errorf("VerifyConsistency(0, %d) with a non-empty old root succeeded", size2)
+				}
+				continue
+			}
+			if size1 == size2 {
+				continue
+			}
+			if err := merkle.VerifyConsistency(uint64(size1), uint64(size2), p, h(roots[size1-1]), r2); err == nil {
+				t.Errorf("VerifyConsistency(%d, %d) with the wrong old root succeeded", size1, size2)
+			}
+			if err := merkle.VerifyConsistency(uint64(size1), uint64(size2), p, r1, h(roots[size2-1])); err == nil {
+				t.Errorf("VerifyConsistency(%d, %d) with the wrong new root succeeded", size1, size2)
+			}
+			for j := range p {
+				q := append([][32]byte(nil), p...)
+				q[j][31] ^= 0x80
+				if err := merkle.VerifyConsistency(uint64(size1), uint64(size2), q, r1, r2); err == nil {
+					t.Errorf("VerifyConsistency(%d, %d) with proof hash %d changed succeeded", size1, size2, j)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Package pow searches for Bitcoin-style proofs of work: a nonce in an
+// 80-byte block header whose SHA256d, read as a little-endian number, is
+// at most a target.
+//
+// The header is two blocks once padded.  When the nonce is in the second
+// block, as it is in Bitcoin (offset 76), the first block is compressed once
+// and its midstate reused, so each nonce costs two compressions: the second
+// block of the header, and the single padded block of the first digest.
+package pow
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// HeaderSize is the size of a block header.
+const HeaderSize = 80
+
+// NonceOffset is where Bitcoin keeps the nonce, little-endian, in the header.
+const NonceOffset = 76
+
+// batch is how many nonces a worker takes at a time.
+const batch = 1 << 12
+
+var (
+	// ErrNotFound is returned when no nonce in the range meets the target.
+	ErrNotFound = errors.New("pow: no nonce found")
+
+	errHeader = errors.New("pow: header must be 80 bytes, with the nonce inside")
+)
+
+// Job is a search for a nonce.
+type Job struct {
+	Header      []byte   // HeaderSize bytes; the nonce in it is ignored
+	NonceOffset int      // where the 4-byte little-endian nonce goes
+	Target      [32]byte // big-endian
+	Workers     int      // goroutines, at least 1
+
+	// Nonces Start to Start+Count-1 are tried; a Count of 0 means up to
+	// the last 32-bit nonce.
+	Start uint32
+	Count uint64
+
+	// If Report is not nil it is called every ReportInterval (default one
+	// second) with the number of hashes so far.
+	Report         func(Stats)
+	ReportInterval time.Duration
+}
+
+// Stats describes a search so far.
+type Stats struct {
+	Hashes  uint64
+	Elapsed time.Duration
+}
+
+// Rate returns the hashes per second.
+func (s Stats) Rate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Hashes) / s.Elapsed.Seconds()
+}
+
+// Result is a nonce found by Search.
+type Result struct {
+	Nonce  uint32
+	Header [HeaderSize]byte // with the nonce
+	Hash   [32]byte         // SHA256d of Header
+	Stats
+}
+
+// Hash returns the SHA256d of a header.
+func Hash(header []byte) [32]byte {
+	return sha2.DoubleSum(header)
+}
+
+// Meets reports whether hash, read as a little-endian number, is at most
+// target, read as a big-endian one.
+func Meets(hash, target *[32]byte) bool {
+	for i := 0; i < 32; i++ {
+		h, t := hash[31-i], target[i]
+		if h != t {
+			return h < t
+		}
+	}
+	return true
+}
+
+// Verify reports whether the SHA256d of header meets target.
+func Verify(header []byte, target [32]byte) bool {
+	h := Hash(header)
+	return len(header) == HeaderSize && Meets(&h, &target)
+}
+
+// TargetFromBits expands the compact "bits" form of a target used in
+// Bitcoin headers: a one-byte exponent and three-byte mantissa.
+func TargetFromBits(bits uint32) [32]byte {
+	exp := uint(bits >> 24)
+	mant := big.NewInt(int64(bits & 0x007fffff))
+	if exp <= 3 {
+		mant.Rsh(mant, 8*(3-exp))
+	} else {
+		mant.Lsh(mant, 8*(exp-3))
+	}
+	var t [32]byte
+	if mant.BitLen() <= 256 {
+		mant.FillBytes(t[:])
+	}
+	return t
+}
+
+// TargetFromZeros returns the target met by hashes with at least n leading
+// zero bits, as Bitcoin displays them (byte-reversed), 0 <= n <= 256.
+func TargetFromZeros(n int) [32]byte {
+	var t [32]byte
+	if n < 0 || n > 256 {
+		panic("pow: zero bits out of range")
+	}
+	x := new(big.Int).Lsh(big.NewInt(1), uint(256-n))
+	x.Sub(x, big.NewInt(1))
+	x.FillBytes(t[:])
+	return t
+}
+
+// Search tries the nonces of job with job.Workers goroutines until one meets
+// the target, the range is exhausted or ctx is cancelled.  Which nonce is
+// found, if several would do, depends on scheduling.
+func Search(ctx context.Context, job Job) (Result, error) {
+	off := job.NonceOffset
+	if len(job.Header) != HeaderSize || off < 0 || off+4 > HeaderSize {
+		return Result{}, errHeader
+	}
+	workers := job.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	end := uint64(1) << 32
+	if job.Count > 0 && uint64(job.Start)+job.Count < end {
+		end = uint64(job.Start) + job.Count
+	}
+
+	// the padded header, and the midstate after its first block
+	var padded [128]byte
+	copy(padded[:], job.Header)
+	copy(padded[HeaderSize:], sha2.Padding(HeaderSize))
+	mid := sha2.Sha256InitialHash()
+	sha2.Sha256Compress(&mid, padded[:64])
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	start := time.Now()
+	var hashes uint64 // atomic
+	next := uint64(job.Start)
+	var once sync.Once
+	var found *Result
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := padded
+			var second [64]byte
+			second[32] = 0x80
+			binary.BigEndian.PutUint64(second[56:], 256)
+			var hash [32]byte
+			for ctx.Err() == nil {
+				lo := atomic.AddUint64(&next, batch) - batch
+				if lo >= end {
+					return
+				}
+				hi := lo + batch
+				if hi > end {
+					hi = end
+				}
+				for n := lo; n < hi; n++ {
+					binary.LittleEndian.PutUint32(buf[off:], uint32(n))
+					h := mid
+					if off < 64 {
+						h = sha2.Sha256InitialHash()
+						sha2.Sha256Compress(&h, buf[:64])
+					}
+					sha2.Sha256Compress(&h, buf[64:])
+					for j := 0; j < 8; j++ {
+						binary.BigEndian.PutUint32(second[j*4:], h[j])
+					}
+					h = sha2.Sha256InitialHash()
+					sha2.Sha256Compress(&h, second[:])
+					for j := 0; j < 8; j++ {
+						binary.BigEndian.PutUint32(hash[j*4:], h[j])
+					}
+					if Meets(&hash, &job.Target) {
+						atomic.AddUint64(&hashes, n-lo+1)
+						once.Do(func() {
+							r := &Result{Nonce: uint32(n), Hash: hash}
+							copy(r.Header[:], buf[:HeaderSize])
+							found = r
+							cancel()
+						})
+						return
+					}
+				}
+				atomic.AddUint64(&hashes, hi-lo)
+			}
+		}()
+	}
+
+	stop := make(chan struct{})
+	var reporter sync.WaitGroup
+	if job.Report != nil {
+		interval := job.ReportInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		reporter.Add(1)
+		go func() {
+			defer reporter.Done()
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-t.C:
+					job.Report(Stats{atomic.LoadUint64(&hashes), time.Since(start)})
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	reporter.Wait()
+
+	stats := Stats{atomic.LoadUint64(&hashes), time.Since(start)}
+	if found != nil {
+		found.Stats = stats
+		return *found, nil
+	}
+	if err := ctx.Err(); err != nil && stats.Hashes < end-uint64(job.Start) {
+		return Result{Stats: stats}, err
+	}
+	return Result{Stats: stats}, ErrNotFound
+}
@@ -0,0 +1,114 @@
+package pow_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/jwatson0/go/gosha256/sha2/pow"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// the Bitcoin genesis block header, nonce 0x7c2bac1d
+var genesis = unhex("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c")
+
+func TestTarget(t *testing.T) {
+	got := pow.TargetFromBits(0x1d00ffff)
+	if want := "00000000ffff0000000000000000000000000000000000000000000000000000"; hex.EncodeToString(got[:]) != want {
+		t.Errorf("TargetFromBits(0x1d00ffff) => %x, want %s", got, want)
+	}
+	got = pow.TargetFromBits(0x03123456)
+	if want := "0000000000000000000000000000000000000000000000000000000000123456"; hex.EncodeToString(got[:]) != want {
+		t.Errorf("TargetFromBits(0x03123456) => %x, want %s", got, want)
+	}
+	got = pow.TargetFromZeros(12)
+	if want := "000fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"; hex.EncodeToString(got[:]) != want {
+		t.Errorf("TargetFromZeros(12) => %x, want %s", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	target := pow.TargetFromBits(0x1d00ffff)
+	if !pow.Verify(genesis, target) {
+		t.Errorf("Verify(genesis) => false")
+	}
+	bad := append([]byte(nil), genesis...)
+	bad[pow.NonceOffset]++
+	if pow.Verify(bad, target) {
+		t.Errorf("Verify(genesis, wrong nonce) => true")
+	}
+	if pow.Verify(genesis[:79], target) {
+		t.Errorf("Verify(short header) => true")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	// the genesis nonce is found a few batches into the range
+	const nonce = 0x7c2bac1d
+	header := append([]byte(nil), genesis...)
+	copy(header[pow.NonceOffset:], []byte{0, 0, 0, 0})
+	for _, workers := range []int{1, 4} {
+		r, err := pow.Search(context.Background(), pow.Job{
+			Header:      header,
+			NonceOffset: pow.NonceOffset,
+			Target:      pow.TargetFromBits(0x1d00ffff),
+			Workers:     workers,
+			Start:       nonce - 20000,
+			Count:       40000,
+		})
+		if err != nil {
+			t.Fatalf("Search(genesis, %d workers) => %v", workers, err)
+		}
+		if r.Nonce != nonce || string(r.Header[:]) != string(genesis) || r.Hash != pow.Hash(genesis) {
+			t.Errorf("Search(genesis, %d workers) => nonce %#x, header %x", workers, r.Nonce, r.Header)
+		}
+		if r.Hashes < 20000 {
+			t.Errorf("Search(genesis, %d workers) => %d hashes, want at least 20000", workers, r.Hashes)
+		}
+	}
+
+	// a range without it
+	_, err := pow.Search(context.Background(), pow.Job{
+		Header: header, NonceOffset: pow.NonceOffset, Target: pow.TargetFromBits(0x1d00ffff),
+		Workers: 2, Start: nonce + 1, Count: 10000,
+	})
+	if err != pow.ErrNotFound {
+		t.Errorf("Search(range without a nonce) => %v, want ErrNotFound", err)
+	}
+
+	// a nonce in the first block, with an easy target
+	r, err := pow.Search(context.Background(), pow.Job{
+		Header: header, NonceOffset: 4, Target: pow.TargetFromZeros(8), Workers: 2,
+	})
+	if err != nil || !pow.Verify(r.Header[:], pow.TargetFromZeros(8)) {
+		t.Errorf("Search(nonce at 4) => %x, %v", r.Header, err)
+	}
+
+	if _, err := pow.Search(context.Background(), pow.Job{Header: header, NonceOffset: 77}); err == nil {
+		t.Errorf("Search(nonce past the end) => no error")
+	}
+}
+
+func TestSearchCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var reports int
+	_, err := pow.Search(ctx, pow.Job{
+		Header: genesis, NonceOffset: pow.NonceOffset, Workers: 2,
+		Report: func(pow.Stats) { reports++ }, ReportInterval: 10 * time.Millisecond,
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Search(impossible target) => %v, want %v", err, context.DeadlineExceeded)
+	}
+	if reports == 0 {
+		t.Errorf("Search reported no hash rate")
+	}
+}
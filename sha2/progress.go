@@ -0,0 +1,120 @@
+package sha2
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Progress counts the bytes being hashed and reports the running total from
+// its own goroutine every interval, so the hashing loop pays only for an
+// atomic add per write.  One Progress may count for many digests at once.
+type Progress struct {
+	n    int64 // accessed atomically
+	fn   func(n int64)
+	last func(n int64) // called by Stop instead of fn, if not nil
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewProgress starts calling fn with the number of bytes counted so far,
+// every interval until Stop.
+func NewProgress(interval time.Duration, fn func(n int64)) *Progress {
+	p := newProgress()
+	p.fn = fn
+	go p.run(interval)
+	return p
+}
+
+// NewProgressChan is NewProgress reporting on a channel.  A report is
+// dropped rather than wait for a slow receiver; the channel is closed by
+// Stop after the final count.
+func NewProgressChan(interval time.Duration) (*Progress, <-chan int64) {
+	c := make(chan int64, 1)
+	p := newProgress()
+	p.fn = func(n int64) {
+		select {
+		case c <- n:
+		default:
+		}
+	}
+	p.last = func(n int64) {
+		// the final count always gets through
+		select {
+		case <-c:
+		default:
+		}
+		c <- n
+		close(c)
+	}
+	go p.run(interval)
+	return p, c
+}
+
+func newProgress() *Progress {
+	return &Progress{stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (p *Progress) run(interval time.Duration) {
+	defer close(p.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			// Stop alone reports once it has begun
+			select {
+			case <-p.stop:
+				return
+			default:
+			}
+			p.fn(p.N())
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Add counts n more bytes.
+func (p *Progress) Add(n int64) {
+	atomic.AddInt64(&p.n, n)
+}
+
+// N returns the bytes counted so far.
+func (p *Progress) N() int64 {
+	return atomic.LoadInt64(&p.n)
+}
+
+// Stop ends the reports and calls fn a last time with the total, which it
+// returns.
+func (p *Progress) Stop() int64 {
+	close(p.stop)
+	<-p.done
+	n := p.N()
+	if p.last != nil {
+		p.last(n)
+	} else {
+		p.fn(n)
+	}
+	return n
+}
+
+// Writer returns a writer that passes writes on to w, usually a Digest,
+// counting them.  A nil Progress returns w itself.
+func (p *Progress) Writer(w io.Writer) io.Writer {
+	if p == nil {
+		return w
+	}
+	return progressWriter{w, p}
+}
+
+type progressWriter struct {
+	w io.Writer
+	p *Progress
+}
+
+func (pw progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.p.Add(int64(n))
+	return n, err
+}
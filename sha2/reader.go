@@ -0,0 +1,101 @@
+package sha2
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrDigestMismatch is returned by a VerifyingReader at the end of data whose
+// digest isn't the one expected.
+var ErrDigestMismatch = errors.New("sha2: digest mismatch")
+
+// readBatch is how much SumReader reads, and hashes, between checks of its
+// context: 512 blocks.
+const readBatch = 512 * Sha256BlocksizeBytes
+
+// SumReader hashes everything read from r, returning the digest and the
+// number of bytes read.  It checks ctx between batches of blocks and returns
+// ctx.Err() once it is cancelled.  SumReader starts no goroutines, so a Read
+// that blocks is not interrupted; close r to unblock it.
+func SumReader(ctx context.Context, r io.Reader) ([32]byte, int64, error) {
+	d := New()
+	buf := make([]byte, readBatch)
+	var n int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return [32]byte{}, n, err
+		}
+		m, err := r.Read(buf)
+		d.Write(buf[:m])
+		n += int64(m)
+		if err == io.EOF {
+			return d.Sum256(), n, nil
+		}
+		if err != nil {
+			return [32]byte{}, n, err
+		}
+	}
+}
+
+// VerifyingReader hashes the data read through it and checks the digest at
+// the end.
+type VerifyingReader struct {
+	r        io.Reader
+	d        *Digest
+	expected [32]byte
+	err      error // sticky, once at the end
+}
+
+// NewVerifyingReader returns a reader of r that returns ErrDigestMismatch
+// instead of io.EOF if the data read doesn't hash to expected.  The data is
+// passed on as it is read, so a caller must not use any of it until the
+// reader has returned io.EOF.
+func NewVerifyingReader(r io.Reader, expected [32]byte) *VerifyingReader {
+	return &VerifyingReader{r: r, d: New(), expected: expected}
+}
+
+func (v *VerifyingReader) Read(b []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	n, err := v.r.Read(b)
+	v.d.Write(b[:n])
+	if err == io.EOF {
+		if v.d.Sum256() != v.expected {
+			err = ErrDigestMismatch
+		}
+		v.err = err
+	}
+	return n, err
+}
+
+// HashingWriter passes writes on to another writer and hashes them.
+type HashingWriter struct {
+	w io.Writer
+	d *Digest
+	n int64
+}
+
+// NewHashingWriter returns a writer to w that hashes what is written.
+func NewHashingWriter(w io.Writer) *HashingWriter {
+	return &HashingWriter{w: w, d: New()}
+}
+
+// Write writes b to the underlying writer, hashing the bytes it accepted.
+func (hw *HashingWriter) Write(b []byte) (int, error) {
+	n, err := hw.w.Write(b)
+	hw.d.Write(b[:n])
+	hw.n += int64(n)
+	return n, err
+}
+
+// Sum256 returns the digest of the bytes written so far.
+func (hw *HashingWriter) Sum256() [32]byte {
+	return hw.d.Sum256()
+}
+
+// Len returns the number of bytes written so far.
+func (hw *HashingWriter) Len() int64 {
+	return hw.n
+}
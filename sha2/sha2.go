@@ -4,7 +4,9 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"io"
+	"io/ioutil"
 	"log"
+	"os"
 	"strconv"
 )
 
@@ -14,6 +16,11 @@ var (
 	LogError *log.Logger
 )
 
+func init() {
+	// quiet by default, so Sha256 can be called without InitLog
+	InitLog(ioutil.Discard, ioutil.Discard, os.Stderr)
+}
+
 func InitLog(traceHandle io.Writer, infoHandle io.Writer, errorHandle io.Writer) {
 	LogTrace = log.New(traceHandle, "TRACE: ", log.Ldate|log.Lmicroseconds|log.Lshortfile)
 	LogInfo = log.New(infoHandle, "INFO: ", log.Ldate|log.Lmicroseconds|log.Lshortfile)
@@ -41,30 +48,17 @@ func pad(n int16, buf []byte) {
 }
 */
 
-// TODO stream 512-byte chunks (via channel?) and retain state between chunks
-// any chunk len >= 440? bits will need an extra block
-/*
-   while not EOF
-     read 512 bits or to EOF
-     if EOF and len<440
-       append stop bit, buffer, and message length to 512
-     if EOF and len>=440 and len<512
-       append stop bit, buffer to 512
-       set flag to create next block with message length only (no stop bit)
-     process block
-     if flag
-       create another 512 block with message length only (no stop bit)
-       process final block
-
-func Sha256Streamed(r Reader??, chan channel??) [32]byte {
-}
+// TODO func Sha256Bitwise(m []byte, lenBits uint64) [32]byte
+// for message lengths that aren't a multiple of 8 bits
 
-func Sha256Bitwise(m []byte, lenBits uint64) [32]byte {
+// Sha256 returns the SHA-256 digest of m.
+func Sha256(m []byte) [32]byte {
+	return Sha256Traced(m, logTrace())
 }
-*/
 
-//
-func Sha256(m []byte) [32]byte {
+// Sha256Traced returns the SHA-256 digest of m, calling the hooks in tr with
+// the intermediate values of every block.  tr may be nil.
+func Sha256Traced(m []byte, tr *Trace) [32]byte {
 	result := [32]byte{}
 
 	LogTrace.Printf("Sha256: ========= input (%d bytes): 0x%s %s\n", len(m), hex.EncodeToString(m), strconv.QuoteToASCII(string(m)))
@@ -72,22 +66,11 @@ func Sha256(m []byte) [32]byte {
 
 	// assumes message lengths are a multiple of 8 bits (byte-aligned)
 	mL := uint64(len(m) * 8) // length in bits
-	//fmt.Printf("Sha256: message length => %d bits\n", mL)
-
-	// calculate length of required padding
-	mPadL := uint64(512 - ((mL + 8 + 64) % 512))
-	LogTrace.Printf("Sha256: need to pad %d bits (%d bytes)\n", mPadL, mPadL/8)
 
 	// create a buffer for padding and length additions
 	// note: don't affect the source message and don't make a copy of it
-	mBuf := make([]byte, (8+64+mPadL)/8)
+	mBuf := Padding(uint64(len(m)))
 	LogTrace.Printf("Sha256: mBuf size %d bits (%d bytes)\n", len(mBuf)*8, len(mBuf))
-
-	// append "1" bit separator followed by "0" padding
-	mBuf[0] = 0x80
-	// append length
-	binary.BigEndian.PutUint64(mBuf[len(mBuf)-8:], mL)
-
 	LogTrace.Printf("Sha256: buffer status: 0x%s|%s\n", hex.EncodeToString(m), hex.EncodeToString(mBuf))
 
 	// calculate the total hashed length
@@ -99,122 +82,156 @@ func Sha256(m []byte) [32]byte {
 		panic("Sha256: total hashed length is not a multiple of 512")
 	}
 
-	// message schedule array
-	w := [64]uint32{}
+	h := Sha256InitialHash()
 
-	h0 := sha256h00
-	h1 := sha256h01
-	h2 := sha256h02
-	h3 := sha256h03
-	h4 := sha256h04
-	h5 := sha256h05
-	h6 := sha256h06
-	h7 := sha256h07
-
-	// loop by 512-bit (64 bytes) chunks
-	for i := uint64(0); i < (hL / 8); i += 64 {
-		LogTrace.Printf("Sha256: new chunk, i==%d\n", i)
-		var chunk []byte
-		// see if this is the last part of original message slice or padding
-		if i+64 >= (mL / 8) {
-			// copy just the last part of the original message slice and append the padding slice
-			chunk = make([]byte, 64)
-			if i >= uint64(len(m)) {
-				// final chunk
-				copy(chunk[:], mBuf[len(mBuf)-64:]) // copy the tail of the buf to chunk
-			} else {
-				tail := len(m[i:])
-				LogTrace.Printf("Sha256: chunk copying last original message slice from %d to %d\n", i, tail)
-				copy(chunk[:], m[i:]) // copy the tail of message to the front of chunk
-
-				final := 64 - tail
-				LogTrace.Printf("Sha256: chunk copying buf len %d to chunk at %d\n", final, tail)
-				copy(chunk[tail:], mBuf[:final]) // copy the buf to the tail of chunk
+	// loop by 512-bit (64 bytes) chunks, using the original message slice
+	// for every chunk that doesn't reach the padding
+	n := 0
+	i := 0
+	for ; i+64 <= len(m); i += 64 {
+		LogTrace.Printf("Sha256: chunk using original message slice from %d to %d\n", i, i+64)
+		block(&h, m[i:i+64], n, 64, tr)
+		n++
+	}
 
-			}
-		} else {
-			// use the original message slice
-			LogTrace.Printf("Sha256: chunk using original message slice from %d to %d\n", i, i+64)
-			chunk = m[i : i+64]
-		}
+	// copy just the last part of the original message slice and append the padding
+	// this is one chunk, or two if the length didn't fit after the "1" bit
+	tail := make([]byte, 0, 2*Sha256BlocksizeBytes)
+	tail = append(tail, m[i:]...)
+	tail = append(tail, mBuf...)
+	LogTrace.Printf("Sha256: chunk copying last original message slice from %d, %d bytes\n", i, len(m)-i)
+	for j := 0; j < len(tail); j += 64 {
+		block(&h, tail[j:j+64], n, 64, tr)
+		n++
+	}
 
-		LogTrace.Printf("Sha256: chunk status: 0x%s\n", hex.EncodeToString(chunk))
+	LogInfo.Printf("Message Digest is  %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X\n", h[0], h[1], h[2], h[3], h[4], h[5], h[6], h[7])
 
-		// scattered, smothered, covered, padded, chunked
+	// copy the final hash output
+	for j := 0; j < 8; j++ {
+		binary.BigEndian.PutUint32(result[j*4:], h[j])
+	}
+	return result
+}
 
-		// copy chunk into first 16 words of w
-		for j := 0; j < 16; j++ {
-			w[j] = uint32(chunk[j*4])<<24 | uint32(chunk[(j*4)+1])<<16 | uint32(chunk[(j*4)+2])<<8 | uint32(chunk[(j*4)+3])
-			LogTrace.Printf("Sha256: copy chunk to w[%2.2d] 0x%8.8X\n", j, w[j])
-		}
+// Padding returns the bytes appended to a message of n bytes before hashing:
+// a "1" bit, "0" bits up to 448 mod 512, and the message length in bits as a
+// 64-bit big-endian integer.  The padded message is a multiple of 512 bits.
+func Padding(n uint64) []byte {
+	// room for the "1" bit (as 0x80) and the 8 byte length, then round up to a block
+	zeros := (Sha256BlocksizeBytes - 9 - n%Sha256BlocksizeBytes + Sha256BlocksizeBytes) % Sha256BlocksizeBytes
+	LogTrace.Printf("Sha256: need to pad %d bits (%d bytes)\n", zeros*8, zeros)
 
-		{
-			LogInfo.Printf("Block Contents:")
-			for i := 0; i < 16; i++ {
-				LogInfo.Printf("  W[%d] = %8.8X", i, w[i])
-			}
-		}
+	p := make([]byte, 1+zeros+8)
+	// append "1" bit separator followed by "0" padding
+	p[0] = 0x80
+	// append length
+	binary.BigEndian.PutUint64(p[len(p)-8:], n*8)
+	return p
+}
 
-		// Extend the first 16 words into the remaining 48 words w[16..63] of the message schedule array:
-		for t := 16; t < 64; t++ {
-			w[t] = lowerSigma1(w[t-2]) + w[t-7] + lowerSigma0(w[t-15]) + w[t-16]
-			LogTrace.Printf("Sha256: extend w[%2.2d] 0x%8.8X\n", t, w[t])
-		}
+// Sha256InitialHash returns the initial hash value H(0).
+func Sha256InitialHash() [8]uint32 {
+	return [8]uint32{sha256h00, sha256h01, sha256h02, sha256h03, sha256h04, sha256h05, sha256h06, sha256h07}
+}
 
-		a := h0
-		b := h1
-		c := h2
-		d := h3
-		e := h4
-		f := h5
-		g := h6
-		h := h7
-
-		LogInfo.Printf("          A        B        C        D        E        F        G        H    \n")
-		for t := 0; t < 64; t++ {
-			uT1 := h + upperSigma1(e) + ch(e, f, g) + sha256kByIndex(t) + w[t]
-			uT2 := upperSigma0(a) + maj(a, b, c)
-			h = g
-			g = f
-			f = e
-			e = d + uT1
-			d = c
-			c = b
-			b = a
-			a = uT1 + uT2
-			LogInfo.Printf("t=%2d: %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X\n", t, a, b, c, d, e, f, g, h)
-		}
+// Sha256Compress runs the compression function on one 512-bit block,
+// updating the intermediate hash value h.  No padding is added.
+func Sha256Compress(h *[8]uint32, chunk []byte) {
+	if len(chunk) != Sha256BlocksizeBytes {
+		panic("Sha256Compress: chunk is not 64 bytes")
+	}
+	block(h, chunk, 0, 64, nil)
+}
 
-		LogInfo.Printf("H[0] = %8.8X + %8.8X = %8.8X\n", h0, a, a+h0)
-		h0 = a + h0
-		LogInfo.Printf("H[1] = %8.8X + %8.8X = %8.8X\n", h1, b, b+h1)
-		h1 = b + h1
-		LogInfo.Printf("H[2] = %8.8X + %8.8X = %8.8X\n", h2, c, c+h2)
-		h2 = c + h2
-		LogInfo.Printf("H[3] = %8.8X + %8.8X = %8.8X\n", h3, d, d+h3)
-		h3 = d + h3
-		LogInfo.Printf("H[4] = %8.8X + %8.8X = %8.8X\n", h4, e, e+h4)
-		h4 = e + h4
-		LogInfo.Printf("H[5] = %8.8X + %8.8X = %8.8X\n", h5, f, f+h5)
-		h5 = f + h5
-		LogInfo.Printf("H[6] = %8.8X + %8.8X = %8.8X\n", h6, g, g+h6)
-		h6 = g + h6
-		LogInfo.Printf("H[7] = %8.8X + %8.8X = %8.8X\n", h7, h, h+h7)
-		h7 = h + h7
-
-		LogTrace.Printf("Sha256: hash state: 0x%8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X\n", a, b, c, d, e, f, g, h)
+// Sha256CompressRounds is Sha256Compress reduced to the first rounds rounds,
+// for analysing the reduced-round function.  The working variables are still
+// added to h afterwards.  tr may be nil.
+func Sha256CompressRounds(h *[8]uint32, chunk []byte, rounds int, tr *Trace) {
+	if len(chunk) != Sha256BlocksizeBytes {
+		panic("Sha256CompressRounds: chunk is not 64 bytes")
+	}
+	if rounds < 0 || rounds > 64 {
+		panic("Sha256CompressRounds: rounds must be 0-64")
 	}
+	block(h, chunk, 0, rounds, tr)
+}
 
-	LogInfo.Printf("Message Digest is  %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X\n", h0, h1, h2, h3, h4, h5, h6, h7)
+// block runs the compression function on one 512-bit chunk, updating the
+// intermediate hash value h.  n is the index of the chunk in the message.
+// Only the first rounds rounds are run; SHA-256 uses all 64.
+func block(h *[8]uint32, chunk []byte, n int, rounds int, tr *Trace) {
+	if tr != nil && tr.Block != nil {
+		tr.Block(n, chunk)
+	}
 
-	// copy the final hash output
-	binary.BigEndian.PutUint32(result[0:4], h0)
-	binary.BigEndian.PutUint32(result[4:8], h1)
-	binary.BigEndian.PutUint32(result[8:12], h2)
-	binary.BigEndian.PutUint32(result[12:16], h3)
-	binary.BigEndian.PutUint32(result[16:20], h4)
-	binary.BigEndian.PutUint32(result[20:24], h5)
-	binary.BigEndian.PutUint32(result[24:28], h6)
-	binary.BigEndian.PutUint32(result[28:32], h7)
-	return result
+	// message schedule array
+	w := [64]uint32{}
+
+	// copy chunk into first 16 words of w
+	for j := 0; j < 16; j++ {
+		w[j] = uint32(chunk[j*4])<<24 | uint32(chunk[(j*4)+1])<<16 | uint32(chunk[(j*4)+2])<<8 | uint32(chunk[(j*4)+3])
+	}
+
+	// Extend the first 16 words into the remaining 48 words w[16..63] of the message schedule array:
+	for t := 16; t < 64; t++ {
+		w[t] = lowerSigma1(w[t-2]) + w[t-7] + lowerSigma0(w[t-15]) + w[t-16]
+	}
+
+	if tr != nil && tr.Schedule != nil {
+		tr.Schedule(n, &w)
+	}
+
+	a := h[0]
+	b := h[1]
+	c := h[2]
+	d := h[3]
+	e := h[4]
+	f := h[5]
+	g := h[6]
+	hh := h[7]
+
+	for t := 0; t < rounds; t++ {
+		k := sha256kByIndex(t)
+		s1 := upperSigma1(e)
+		c1 := ch(e, f, g)
+		s0 := upperSigma0(a)
+		m1 := maj(a, b, c)
+		uT1 := hh + s1 + c1 + k + w[t]
+		uT2 := s0 + m1
+		hh = g
+		g = f
+		f = e
+		e = d + uT1
+		d = c
+		c = b
+		b = a
+		a = uT1 + uT2
+		if tr != nil && tr.Round != nil {
+			r := Round{
+				T: t, W: w[t], K: k, T1: uT1, T2: uT2,
+				Ch: c1, Maj: m1, UpperSigma0: s0, UpperSigma1: s1,
+				State: [8]uint32{a, b, c, d, e, f, g, hh},
+			}
+			if t >= 16 {
+				r.LowerSigma0 = lowerSigma0(w[t-15])
+				r.LowerSigma1 = lowerSigma1(w[t-2])
+			}
+			tr.Round(n, r)
+		}
+	}
+
+	prev := *h
+	h[0] += a
+	h[1] += b
+	h[2] += c
+	h[3] += d
+	h[4] += e
+	h[5] += f
+	h[6] += g
+	h[7] += hh
+
+	if tr != nil && tr.Digest != nil {
+		tr.Digest(n, prev, [8]uint32{a, b, c, d, e, f, g, hh}, *h)
+	}
 }
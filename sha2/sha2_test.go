@@ -2,12 +2,15 @@ package sha2_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/jwatson0/go/gosha256/sha2"
 )
@@ -152,3 +155,316 @@ func TestSha256(t *testing.T) {
 		}
 	}
 }
+
+func TestSha256Compress(t *testing.T) {
+	// the padded "abc" message is one block
+	m := append([]byte("abc"), sha2.Padding(3)...)
+	h := sha2.Sha256InitialHash()
+	sha2.Sha256Compress(&h, m)
+	want := [8]uint32{0xBA7816BF, 0x8F01CFEA, 0x414140DE, 0x5DAE2223, 0xB00361A3, 0x96177A9C, 0xB410FF61, 0xF20015AD}
+	if h != want {
+		t.Errorf("sha2.Sha256Compress(abc) => %8.8X, want %8.8X", h, want)
+	}
+
+	// zero rounds only adds H(0) to itself
+	h = sha2.Sha256InitialHash()
+	sha2.Sha256CompressRounds(&h, m, 0, nil)
+	for i, x := range sha2.Sha256InitialHash() {
+		if h[i] != x+x {
+			t.Errorf("sha2.Sha256CompressRounds(abc, 0) => H[%d] %8.8X, want %8.8X", i, h[i], x+x)
+		}
+	}
+}
+
+func TestPadding(t *testing.T) {
+	for n := uint64(0); n < 200; n++ {
+		p := sha2.Padding(n)
+		if (n+uint64(len(p)))%64 != 0 || len(p) < 9 || len(p) > 72 || p[0] != 0x80 {
+			t.Errorf("sha2.Padding(%d) => %d bytes 0x%s", n, len(p), hex.EncodeToString(p))
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+	m := make([]byte, 1000)
+	for i := range m {
+		m[i] = byte(i * 7)
+	}
+	d := sha2.New()
+	for n := 0; n <= len(m); n += 37 {
+		want := sha2.Sha256(m[:n])
+		// write in uneven pieces
+		d.Reset()
+		for i, step := 0, 1; i < n; i, step = i+step, step*3%65+1 {
+			end := i + step
+			if end > n {
+				end = n
+			}
+			d.Write(m[i:end])
+		}
+		if got := d.Sum256(); got != want {
+			t.Errorf("sha2.Digest of %d bytes => %x, want %x", n, got, want)
+		}
+		// Sum doesn't change the state
+		if got := d.Sum(nil); !bytes.Equal(got, want[:]) {
+			t.Errorf("sha2.Digest.Sum of %d bytes => %x, want %x", n, got, want)
+		}
+	}
+
+	// a clone goes on from the same state, independently
+	d.Reset()
+	d.Write(m[:100])
+	c := d.Clone()
+	d.Write(m[100:])
+	c.Write(m[100:150])
+	if got, want := d.Sum256(), sha2.Sha256(m); got != want {
+		t.Errorf("sha2.Digest after Clone => %x, want %x", got, want)
+	}
+	if got, want := c.Sum256(), sha2.Sha256(m[:150]); got != want {
+		t.Errorf("sha2.Digest.Clone => %x, want %x", got, want)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var last int64
+	calls := 0
+	p := sha2.NewProgress(time.Millisecond, func(n int64) {
+		if n < last {
+			t.Errorf("sha2.Progress reported %d after %d", n, last)
+		}
+		last = n
+		calls++
+	})
+	d := sha2.New()
+	w := p.Writer(d)
+	m := make([]byte, 100000)
+	for i := 0; i < 10; i++ {
+		w.Write(m[:i*1000])
+	}
+	if n := p.Stop(); n != 45000 || last != 45000 || calls == 0 {
+		t.Errorf("sha2.Progress.Stop() => %d, last report %d after %d calls, want 45000", n, last, calls)
+	}
+	if d.Sum256() != sha2.Sha256(m[:45000]) {
+		t.Errorf("sha2.Progress.Writer changed the data written")
+	}
+
+	p, c := sha2.NewProgressChan(time.Hour)
+	p.Writer(d).Write(m)
+	p.Stop()
+	if n, ok := <-c; n != 100000 || !ok {
+		t.Errorf("sha2.NewProgressChan sent %d, %v, want 100000", n, ok)
+	}
+	if _, ok := <-c; ok {
+		t.Errorf("sha2.NewProgressChan channel not closed after Stop")
+	}
+
+	var nilp *sha2.Progress
+	if nilp.Writer(d) != io.Writer(d) {
+		t.Errorf("nil sha2.Progress.Writer doesn't return the writer")
+	}
+}
+
+// TestProgressStop stops reports racing the ticker, which mustn't send the
+// final count or close the channel before Stop does.
+func TestProgressStop(t *testing.T) {
+	for i := 0; i < 2000; i++ {
+		p, c := sha2.NewProgressChan(time.Microsecond)
+		p.Add(int64(i))
+		if i%2 == 0 {
+			time.Sleep(time.Duration(i%7) * time.Microsecond)
+		}
+		if n := p.Stop(); n != int64(i) {
+			t.Fatalf("sha2.Progress.Stop() => %d, want %d", n, i)
+		}
+		var last int64 = -1
+		for n := range c {
+			last = n
+		}
+		if last != int64(i) {
+			t.Fatalf("sha2.NewProgressChan last sent %d, want %d", last, i)
+		}
+	}
+}
+
+// zeros is an endless stream of zero bytes
+type zeros struct{}
+
+func (zeros) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+func TestSumReader(t *testing.T) {
+	m := make([]byte, 100000)
+	for i := range m {
+		m[i] = byte(i)
+	}
+	sum, n, err := sha2.SumReader(context.Background(), iotest.HalfReader(bytes.NewReader(m)))
+	if sum != sha2.Sha256(m) || n != int64(len(m)) || err != nil {
+		t.Errorf("sha2.SumReader() => %x, %d, %v, want %x, %d", sum, n, err, sha2.Sha256(m), len(m))
+	}
+
+	_, _, err = sha2.SumReader(context.Background(), iotest.TimeoutReader(bytes.NewReader(m)))
+	if err != iotest.ErrTimeout {
+		t.Errorf("sha2.SumReader() error %v, want %v", err, iotest.ErrTimeout)
+	}
+
+	// cancelled while reading an endless stream
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	sum, n, err = sha2.SumReader(ctx, zeros{})
+	if err != context.Canceled || sum != [32]byte{} || n == 0 {
+		t.Errorf("cancelled sha2.SumReader() => %x, %d, %v", sum, n, err)
+	}
+
+	// already cancelled
+	_, n, err = sha2.SumReader(ctx, bytes.NewReader(m))
+	if err != context.Canceled || n != 0 {
+		t.Errorf("sha2.SumReader() with a cancelled context => %d, %v", n, err)
+	}
+}
+
+func TestVerifyingReader(t *testing.T) {
+	m := make([]byte, 5000)
+	for i := range m {
+		m[i] = byte(i * 3)
+	}
+	sum := sha2.Sha256(m)
+
+	got, err := ioutil.ReadAll(sha2.NewVerifyingReader(iotest.OneByteReader(bytes.NewReader(m)), sum))
+	if err != nil || !bytes.Equal(got, m) {
+		t.Errorf("sha2.VerifyingReader with the right digest => %d bytes, %v", len(got), err)
+	}
+
+	bad := sum
+	bad[31] ^= 1
+	v := sha2.NewVerifyingReader(bytes.NewReader(m), bad)
+	if _, err := ioutil.ReadAll(v); err != sha2.ErrDigestMismatch {
+		t.Errorf("sha2.VerifyingReader with the wrong digest => %v, want %v", err, sha2.ErrDigestMismatch)
+	}
+	if n, err := v.Read(make([]byte, 10)); n != 0 || err != sha2.ErrDigestMismatch {
+		t.Errorf("sha2.VerifyingReader read after a mismatch => %d, %v", n, err)
+	}
+
+	// a short stream is a mismatch too
+	if _, err := ioutil.ReadAll(sha2.NewVerifyingReader(bytes.NewReader(m[:4999]), sum)); err != sha2.ErrDigestMismatch {
+		t.Errorf("sha2.VerifyingReader of truncated data => %v, want %v", err, sha2.ErrDigestMismatch)
+	}
+}
+
+func TestHashingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := sha2.NewHashingWriter(&buf)
+	fmt.Fprintf(w, "a")
+	fmt.Fprintf(w, "bc")
+	if w.Sum256() != sha2.Sha256([]byte("abc")) || w.Len() != 3 || buf.String() != "abc" {
+		t.Errorf("sha2.HashingWriter of abc => %x, %d, %q", w.Sum256(), w.Len(), buf.String())
+	}
+}
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestDoubleSum(t *testing.T) {
+	var m64 [64]byte
+	for i := range m64 {
+		m64[i] = byte(i)
+	}
+	for _, tc := range []struct {
+		m    []byte
+		want string
+	}{
+		{nil, "5df6e0e2761359d30a8275058e299fcc0381534545f55cf43e41983f5d4c9456"},
+		{[]byte("hello"), "9595c9df90075148eb06860365df33584b75bff782a510c6cd4883a419833d50"},
+		{m64[:], "01c9f464780a1b6af4eb400fe2f2896cfb2169f5a65701439e4c2c4e213903ef"},
+	} {
+		if got := sha2.DoubleSum(tc.m); hex.EncodeToString(got[:]) != tc.want {
+			t.Errorf("sha2.DoubleSum(%q) => %x, want %s", tc.m, got, tc.want)
+		}
+	}
+	if got, want := sha2.DoubleSum64(&m64), sha2.DoubleSum(m64[:]); got != want {
+		t.Errorf("sha2.DoubleSum64() => %x, want %x", got, want)
+	}
+
+	// the Bitcoin genesis block header, whose hash is shown byte-reversed
+	genesis := unhex("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c")
+	got := sha2.DoubleSum(genesis)
+	for i, j := 0, len(got)-1; i < j; i, j = i+1, j-1 {
+		got[i], got[j] = got[j], got[i]
+	}
+	if want := "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"; hex.EncodeToString(got[:]) != want {
+		t.Errorf("sha2.DoubleSum(genesis header) => %x, want %s", got, want)
+	}
+}
+
+func TestTagged(t *testing.T) {
+	// the midstates hard-coded in libsecp256k1 for the BIP-340 tags
+	for tag, want := range map[string][8]uint32{
+		"BIP0340/aux":       {0x24dd3219, 0x4eba7e70, 0xca0fabb9, 0x0fa3166d, 0x3afbe4b1, 0x4c44df97, 0x4aac2739, 0x249e850a},
+		"BIP0340/nonce":     {0x46615b35, 0xf4bfbff7, 0x9f8dc671, 0x83627ab3, 0x60217180, 0x57358661, 0x21a29e54, 0x68b07b4c},
+		"BIP0340/challenge": {0x9cecba11, 0x23925381, 0x11679112, 0xd1627e0f, 0x97c87550, 0x003cc765, 0x90f61164, 0x33e9b66a},
+	} {
+		if got := sha2.NewTag(tag).Midstate(); got != want {
+			t.Errorf("sha2.NewTag(%q).Midstate() => %08x, want %08x", tag, got, want)
+		}
+	}
+
+	var m64 [64]byte
+	for i := range m64 {
+		m64[i] = byte(i)
+	}
+	for _, tc := range []struct {
+		tag  string
+		msg  []byte
+		want string
+	}{
+		{"BIP0340/challenge", nil, "c216d352f5818b7b4beacd4ae0a26fe888080823d2a598856661bcd54f1b3713"},
+		{"BIP0340/challenge", []byte("abc"), "770a5b7e7c304bbcc3ea107343ff951dd404312ef418db0c3b94e2ebfbb50087"},
+		{"TapLeaf", []byte{0xc0, 0x01, 0x51}, "a85b2107f791b26a84e7586c28cec7cb61202ed3d01944d832500f363782d675"},
+		{"TapBranch", m64[:], "0be5e29fefe115d2050ca29f079b8e79b395b966234aeeba35ed6223cbe085f8"},
+	} {
+		tag := sha2.NewTag(tc.tag)
+		if got := tag.Sum(tc.msg); hex.EncodeToString(got[:]) != tc.want {
+			t.Errorf("tagged hash %q of %x => %x, want %s", tc.tag, tc.msg, got, tc.want)
+		}
+		d := sha2.NewTagged(tc.tag)
+		d.Write(tc.msg)
+		if got := d.Sum256(); hex.EncodeToString(got[:]) != tc.want {
+			t.Errorf("sha2.NewTagged(%q) of %x => %x, want %s", tc.tag, tc.msg, got, tc.want)
+		}
+		// the same as hashing the whole prefix
+		th := sha2.Sha256([]byte(tc.tag))
+		if got, want := tag.Sum(tc.msg), sha2.Sha256(append(append(th[:], th[:]...), tc.msg...)); got != want {
+			t.Errorf("tagged hash %q of %x => %x, want %x", tc.tag, tc.msg, got, want)
+		}
+	}
+	if got, want := sha2.NewTag("TapBranch").Sum64(&m64), sha2.NewTag("TapBranch").Sum(m64[:]); got != want {
+		t.Errorf("Tag.Sum64() => %x, want %x", got, want)
+	}
+
+	d := sha2.New()
+	d.Write(make([]byte, 128))
+	h, n, ok := d.Midstate()
+	d.Write([]byte("abc"))
+	if !ok || n != 128 {
+		t.Fatalf("Digest.Midstate() => %d, %v", n, ok)
+	}
+	e := sha2.NewFromMidstate(h, n)
+	e.Write([]byte("abc"))
+	if e.Sum256() != d.Sum256() {
+		t.Errorf("sha2.NewFromMidstate() digest differs from continuing")
+	}
+	if _, _, ok := d.Midstate(); ok {
+		t.Errorf("Digest.Midstate() ok part way through a block")
+	}
+}
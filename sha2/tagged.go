@@ -0,0 +1,126 @@
+package sha2
+
+import "encoding/binary"
+
+// NewFromMidstate returns a Digest that continues from the intermediate hash
+// value h after n bytes have been hashed, n being a multiple of the block
+// size.  It lets a fixed prefix be hashed once and reused.
+func NewFromMidstate(h [8]uint32, n uint64) *Digest {
+	if n%Sha256BlocksizeBytes != 0 {
+		panic("NewFromMidstate: length is not a multiple of the block size")
+	}
+	return &Digest{h: h, len: n}
+}
+
+// Midstate returns the intermediate hash value and the number of bytes
+// written, for NewFromMidstate.  ok is false unless the bytes written fill
+// whole blocks.
+func (d *Digest) Midstate() (h [8]uint32, n uint64, ok bool) {
+	return d.h, d.len, d.nx == 0
+}
+
+// padBlock returns the final block of a message of n bytes, n a multiple of
+// the block size: the padding alone, as Padding would return it.  (Padding
+// logs, and the loggers aren't set up when this runs.)
+func padBlock(n uint64) (b [Sha256BlocksizeBytes]byte) {
+	b[0] = 0x80
+	binary.BigEndian.PutUint64(b[56:], n*8)
+	return b
+}
+
+var (
+	pad64  = padBlock(64)
+	pad128 = padBlock(128)
+)
+
+// digestOf returns the big-endian bytes of an intermediate hash value.
+func digestOf(h *[8]uint32) (d [Sha256Size]byte) {
+	for j := 0; j < 8; j++ {
+		binary.BigEndian.PutUint32(d[j*4:], h[j])
+	}
+	return d
+}
+
+// sum32 returns the SHA-256 of a 32-byte value, which with its padding is
+// a single block.
+func sum32(v *[Sha256Size]byte) [Sha256Size]byte {
+	var b [Sha256BlocksizeBytes]byte
+	copy(b[:], v[:])
+	b[32] = 0x80
+	binary.BigEndian.PutUint64(b[56:], 256)
+	h := Sha256InitialHash()
+	block(&h, b[:], 0, 64, nil)
+	return digestOf(&h)
+}
+
+// DoubleSum returns SHA-256(SHA-256(m)), the SHA256d used by Bitcoin.
+func DoubleSum(m []byte) [Sha256Size]byte {
+	d := New()
+	d.Write(m)
+	first := d.Sum256()
+	return sum32(&first)
+}
+
+// DoubleSum64 is DoubleSum of a 64-byte message, such as the two child
+// hashes of a Bitcoin Merkle tree node: three compressions with fixed
+// padding blocks.
+func DoubleSum64(m *[64]byte) [Sha256Size]byte {
+	h := Sha256InitialHash()
+	block(&h, m[:], 0, 64, nil)
+	block(&h, pad64[:], 1, 64, nil)
+	first := digestOf(&h)
+	return sum32(&first)
+}
+
+// Tag is the midstate of a BIP-340 tagged hash,
+// SHA-256(SHA-256(tag) || SHA-256(tag) || msg): the two tag hashes fill
+// the first block, which is compressed once.
+type Tag struct {
+	h [8]uint32
+}
+
+// NewTag hashes the prefix of tag.
+func NewTag(tag string) *Tag {
+	d := New()
+	d.Write([]byte(tag))
+	th := d.Sum256()
+	t := &Tag{h: Sha256InitialHash()}
+	var b [Sha256BlocksizeBytes]byte
+	copy(b[:], th[:])
+	copy(b[32:], th[:])
+	block(&t.h, b[:], 0, 64, nil)
+	return t
+}
+
+// Midstate returns the intermediate hash value after the tag prefix.
+func (t *Tag) Midstate() [8]uint32 {
+	return t.h
+}
+
+// New returns a Digest with the tag prefix already hashed.
+func (t *Tag) New() *Digest {
+	return NewFromMidstate(t.h, Sha256BlocksizeBytes)
+}
+
+// Sum returns the tagged hash of msg.
+func (t *Tag) Sum(msg []byte) [Sha256Size]byte {
+	d := t.New()
+	d.Write(msg)
+	return d.Sum256()
+}
+
+// Sum64 returns the tagged hash of a 64-byte message, such as a Taproot
+// branch: two compressions, the second of a fixed padding block.
+func (t *Tag) Sum64(msg *[64]byte) [Sha256Size]byte {
+	h := t.h
+	block(&h, msg[:], 1, 64, nil)
+	block(&h, pad128[:], 2, 64, nil)
+	return digestOf(&h)
+}
+
+// NewTagged returns a Digest for the BIP-340 tagged hash with tag, ready
+// for the message to be written.  Use NewTag to reuse the midstate for
+// many messages.
+func NewTagged(tag string) *Digest {
+	return NewTag(tag).New()
+}
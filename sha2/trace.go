@@ -0,0 +1,83 @@
+package sha2
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+)
+
+// Round holds the values computed by one round t of the compression function.
+type Round struct {
+	T  int
+	W  uint32 // message schedule word W[t]
+	K  uint32 // round constant K[t]
+	T1 uint32
+	T2 uint32
+
+	// components of T1 and T2, from the working variables before the round
+	Ch          uint32 // Ch(e, f, g)
+	Maj         uint32 // Maj(a, b, c)
+	UpperSigma0 uint32 // Σ0(a)
+	UpperSigma1 uint32 // Σ1(e)
+
+	// components of W[t] = σ1(W[t-2]) + W[t-7] + σ0(W[t-15]) + W[t-16], zero for t < 16
+	LowerSigma0 uint32 // σ0(W[t-15])
+	LowerSigma1 uint32 // σ1(W[t-2])
+
+	// working variables a..h after the round
+	State [8]uint32
+}
+
+// Trace is a set of hooks called by the compression function with its
+// intermediate values, in the order the FIPS 180 examples list them.
+// Any hook may be nil.  n is the index of the 512-bit block in the padded message.
+type Trace struct {
+	// Block is called with the 64 bytes of block n before it is processed.
+	Block func(n int, chunk []byte)
+
+	// Schedule is called with the message schedule W[0..63] of block n.
+	Schedule func(n int, w *[64]uint32)
+
+	// Round is called after each round of block n, 64 unless reduced by Sha256CompressRounds.
+	Round func(n int, r Round)
+
+	// Digest is called after block n with the previous intermediate hash
+	// value, the working variables a..h added to it, and the result.
+	Digest func(n int, prev, work, next [8]uint32)
+}
+
+// logTrace returns a Trace that writes the intermediate values to LogInfo and
+// LogTrace, or nil when both are discarded.
+func logTrace() *Trace {
+	if LogInfo.Writer() == ioutil.Discard && LogTrace.Writer() == ioutil.Discard {
+		return nil
+	}
+	return &Trace{
+		Block: func(n int, chunk []byte) {
+			LogTrace.Printf("Sha256: new chunk, n==%d\n", n)
+			LogTrace.Printf("Sha256: chunk status: 0x%s\n", hex.EncodeToString(chunk))
+		},
+		Schedule: func(n int, w *[64]uint32) {
+			for j := 0; j < 16; j++ {
+				LogTrace.Printf("Sha256: copy chunk to w[%2.2d] 0x%8.8X\n", j, w[j])
+			}
+			LogInfo.Printf("Block Contents:")
+			for j := 0; j < 16; j++ {
+				LogInfo.Printf("  W[%d] = %8.8X", j, w[j])
+			}
+			for t := 16; t < 64; t++ {
+				LogTrace.Printf("Sha256: extend w[%2.2d] 0x%8.8X\n", t, w[t])
+			}
+			LogInfo.Printf("          A        B        C        D        E        F        G        H    \n")
+		},
+		Round: func(n int, r Round) {
+			s := r.State
+			LogInfo.Printf("t=%2d: %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X\n", r.T, s[0], s[1], s[2], s[3], s[4], s[5], s[6], s[7])
+		},
+		Digest: func(n int, prev, work, next [8]uint32) {
+			for j := 0; j < 8; j++ {
+				LogInfo.Printf("H[%d] = %8.8X + %8.8X = %8.8X\n", j, prev[j], work[j], next[j])
+			}
+			LogTrace.Printf("Sha256: hash state: 0x%8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X %8.8X\n", work[0], work[1], work[2], work[3], work[4], work[5], work[6], work[7])
+		},
+	}
+}
@@ -0,0 +1,203 @@
+// Package treehash hashes large inputs in parallel: the input is cut into
+// fixed-size chunks, which are hashed by separate goroutines and their
+// digests combined.  The result is not the SHA-256 of the input, but of a
+// tree over it, and depends on the scheme and its chunk size.
+//
+// Three schemes are provided:
+//
+// Dropbox is the content_hash of the Dropbox API: the SHA-256 of the
+// concatenated SHA-256 digests of 4 MiB blocks.
+//
+// Glacier is the tree hash of Amazon S3 Glacier: the SHA-256 digests of
+// 1 MiB chunks are combined in pairs, SHA-256(left || right), level by
+// level, an odd digest at the end of a level moving up unchanged.
+//
+// Merkle is the Merkle tree of RFC 6962 over 1 MiB chunks, with domain
+// separation: a chunk is hashed as SHA-256(0x00 || chunk) and an interior
+// node as SHA-256(0x01 || left || right), so neither a chunk nor the
+// concatenation of digests can be passed off as the other.  The tree of n
+// chunks is split after the largest power of two less than n, as in the
+// sha2/merkle package.
+//
+// All three give the SHA-256 of nothing for an empty input.
+package treehash
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/merkle"
+)
+
+const mib = 1 << 20
+
+// Scheme is a way of cutting an input into chunks and combining their
+// digests.
+type Scheme struct {
+	Name      string
+	ChunkSize int
+	leaf      func(chunk []byte) [32]byte
+	combine   func(leaves [][32]byte) [32]byte
+}
+
+var (
+	Dropbox = &Scheme{Name: "dropbox", ChunkSize: 4 * mib, leaf: sum, combine: concat}
+	Glacier = &Scheme{Name: "glacier", ChunkSize: mib, leaf: sum, combine: pairs}
+	Merkle  = &Scheme{Name: "merkle", ChunkSize: mib, leaf: merkle.LeafHash, combine: merkleRoot}
+)
+
+// Schemes lists the schemes by name.
+var Schemes = []*Scheme{Dropbox, Glacier, Merkle}
+
+// Lookup returns the scheme called name, or nil.
+func Lookup(name string) *Scheme {
+	for _, s := range Schemes {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// WithChunkSize returns a copy of s cutting chunks of size bytes.
+func (s *Scheme) WithChunkSize(size int) *Scheme {
+	if size < 1 {
+		panic("treehash: chunk size must be positive")
+	}
+	c := *s
+	c.ChunkSize = size
+	return &c
+}
+
+// sum returns the SHA-256 of a chunk, streamed through a Digest, which
+// unlike sha2.Sha256 doesn't format the chunk for the logs
+func sum(chunk []byte) [32]byte {
+	d := sha2.New()
+	d.Write(chunk)
+	return d.Sum256()
+}
+
+// concat returns the SHA-256 of the leaves one after another.
+func concat(leaves [][32]byte) [32]byte {
+	d := sha2.New()
+	for i := range leaves {
+		d.Write(leaves[i][:])
+	}
+	return d.Sum256()
+}
+
+// pairs combines the leaves in pairs, level by level.
+func pairs(leaves [][32]byte) [32]byte {
+	if len(leaves) == 0 {
+		return sha2.Sha256(nil)
+	}
+	for len(leaves) > 1 {
+		var next [][32]byte
+		for i := 0; i < len(leaves); i += 2 {
+			if i+1 == len(leaves) {
+				next = append(next, leaves[i])
+				break
+			}
+			d := sha2.New()
+			d.Write(leaves[i][:])
+			d.Write(leaves[i+1][:])
+			next = append(next, d.Sum256())
+		}
+		leaves = next
+	}
+	return leaves[0]
+}
+
+func merkleRoot(leaves [][32]byte) [32]byte {
+	t := merkle.New()
+	for _, h := range leaves {
+		t.AppendHash(h)
+	}
+	return t.Root()
+}
+
+// Sum returns the digest of b.
+func (s *Scheme) Sum(b []byte) [32]byte {
+	var leaves [][32]byte
+	for len(b) > 0 {
+		n := s.ChunkSize
+		if n > len(b) {
+			n = len(b)
+		}
+		leaves = append(leaves, s.leaf(b[:n]))
+		b = b[n:]
+	}
+	return s.combine(leaves)
+}
+
+// SumReader returns the digest of everything read from r and its length,
+// hashing chunks with up to workers goroutines.  At most workers chunks are
+// held in memory at once.  It stops with ctx.Err() once ctx is cancelled.
+func (s *Scheme) SumReader(ctx context.Context, r io.Reader, workers int) ([32]byte, int64, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	type job struct {
+		i     int
+		chunk []byte
+	}
+	free := make(chan []byte, workers)
+	for w := 0; w < workers; w++ {
+		free <- make([]byte, s.ChunkSize)
+	}
+	jobs := make(chan job)
+
+	var mu sync.Mutex
+	var leaves [][32]byte
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				h := s.leaf(j.chunk)
+				mu.Lock()
+				leaves[j.i] = h
+				mu.Unlock()
+				free <- j.chunk[:cap(j.chunk)]
+			}
+		}()
+	}
+
+	var n int64
+	var err error
+	for i := 0; ; i++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var buf []byte
+		select {
+		case buf = <-free:
+		case <-ctx.Done():
+			continue
+		}
+		m, rerr := io.ReadFull(r, buf)
+		n += int64(m)
+		if m > 0 {
+			mu.Lock()
+			leaves = append(leaves, [32]byte{})
+			mu.Unlock()
+			jobs <- job{i, buf[:m]}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			err = rerr
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return [32]byte{}, n, err
+	}
+	return s.combine(leaves), n, nil
+}
@@ -0,0 +1,104 @@
+package treehash_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+	"testing/iotest"
+
+	"github.com/jwatson0/go/gosha256/sha2/treehash"
+)
+
+const mib = 1 << 20
+
+// data returns n bytes of a fixed pattern
+func data(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i*7 + i/251)
+	}
+	return b
+}
+
+// computed independently with Python's hashlib
+var vectors = []struct {
+	n                        int
+	dropbox, glacier, merkle string
+}{
+	{0,
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	{1,
+		"1406e05881e299367766d313e26c05564ec91bf721d31726bd6e46e60689539a",
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7"},
+	{mib,
+		"87c51d8df8c08ae3aef390ad634b258177ec139262c7c9bd23f6ee553a4bc10c",
+		"07f4465ef6fe98070beaf8d8d01454b5d11f6cd4ff86a139d92cd031b46ddfdc",
+		"9a89ee23cb57693e7eb99ddad0efd925390f346ad1720e5c3ee5486f0d0ea013"},
+	{mib + 1,
+		"52c0b13a90d0c973a8f042a8b11e33b10fffd01e77b3a82b95ac336f0e30ecbd",
+		"99541925ae307285cfcff8411df09bff987636d574e5e76fe6fd78ca5eea0300",
+		"f5d6474145f2f67ba84ac010550db35bcf2038522250191fc41b53493aedc620"},
+	{4 * mib,
+		"56efd10756134401ee860fc4a3d0eb99bad4be7377d696a68fe5fcb949eef227",
+		"691cca4f23eb9b5ddbf45d91900417d56f480bc4e1281384ac30ca432a513abc",
+		"a14f21aaa69a2b6e76f58995c3289c311a941091ba66a091b1a2e2a1051dc9d2"},
+	{4*mib + 1,
+		"fe67b84ce96fc772bfa690f509c6963604a676d1a4c61eede13b237fe61f0252",
+		"bb1fd687e7ab1c7967206b3e7259b7576e6c9befab219da4ac3dd29ee789579b",
+		"2a7f94ad6d278415a604825b47bfa072fdb135060ec5fa26aa94bd5a0c969d1a"},
+	{9*mib + 12345,
+		"8df4ad47333051e9c4e77097d52a1e301d45f62e988e873b2c51fcd5b8062505",
+		"1bd28955a2153bb5b76c5eb1ad2376962d60609b955dd7dcdbbdcfdeae49c677",
+		"b72d2d26ab45c4eac7b84e5fddfd232aa167a4baeca09703f8eb8f7b79615e99"},
+}
+
+func TestSchemes(t *testing.T) {
+	for _, v := range vectors {
+		b := data(v.n)
+		for _, c := range []struct {
+			s    *treehash.Scheme
+			want string
+		}{{treehash.Dropbox, v.dropbox}, {treehash.Glacier, v.glacier}, {treehash.Merkle, v.merkle}} {
+			if got := c.s.Sum(b); hex.EncodeToString(got[:]) != c.want {
+				t.Errorf("%s.Sum(%d bytes) => %x, want %s", c.s.Name, v.n, got, c.want)
+			}
+			for _, workers := range []int{1, 3} {
+				got, n, err := c.s.SumReader(context.Background(), iotest.HalfReader(bytes.NewReader(b)), workers)
+				if err != nil || n != int64(v.n) || hex.EncodeToString(got[:]) != c.want {
+					t.Errorf("%s.SumReader(%d bytes, %d workers) => %x, %d, %v, want %s", c.s.Name, v.n, workers, got, n, err, c.want)
+				}
+			}
+		}
+	}
+}
+
+func TestSumReaderErrors(t *testing.T) {
+	b := data(3 * mib)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := treehash.Glacier.SumReader(ctx, bytes.NewReader(b), 2); err != context.Canceled {
+		t.Errorf("SumReader with a cancelled context => %v", err)
+	}
+	if _, _, err := treehash.Glacier.SumReader(context.Background(), iotest.TimeoutReader(bytes.NewReader(b)), 2); err != iotest.ErrTimeout {
+		t.Errorf("SumReader with a failing reader => %v", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, s := range treehash.Schemes {
+		if treehash.Lookup(s.Name) != s {
+			t.Errorf("Lookup(%q) didn't find it", s.Name)
+		}
+	}
+	if treehash.Lookup("md5") != nil {
+		t.Errorf("Lookup(md5) found a scheme")
+	}
+	small := treehash.Merkle.WithChunkSize(1)
+	if small.Sum([]byte{0}) != treehash.Merkle.Sum([]byte{0}) || small.Sum([]byte("ab")) == treehash.Merkle.Sum([]byte("ab")) {
+		t.Errorf("WithChunkSize(1) doesn't change the chunking")
+	}
+}
@@ -220,6 +220,11 @@ func sha256kByIndex(i int) uint32 {
 	}
 }
 
+// Sha256K returns the round constant K[t], or 0 if t is not 0-63.
+func Sha256K(t int) uint32 {
+	return sha256kByIndex(t)
+}
+
 // Ch(x, y, z)=(x and y) xor ( complement x and z)
 // Ch(x, y, z)=(x & y) ^ ( ^x & z)
 // "Choose" the bit from y or z based on the bit in x
@@ -275,3 +280,24 @@ func shr(n uint8, x uint32) uint32 {
 		return x >> n
 	}
 }
+
+// The logical functions of the FIPS 180 spec, exported for packages that
+// model the compression function rather than run it.
+
+// Ch returns Ch(x, y, z).
+func Ch(x, y, z uint32) uint32 { return ch(x, y, z) }
+
+// Maj returns Maj(x, y, z).
+func Maj(x, y, z uint32) uint32 { return maj(x, y, z) }
+
+// UpperSigma0 returns Σ0(x).
+func UpperSigma0(x uint32) uint32 { return upperSigma0(x) }
+
+// UpperSigma1 returns Σ1(x).
+func UpperSigma1(x uint32) uint32 { return upperSigma1(x) }
+
+// LowerSigma0 returns σ0(x).
+func LowerSigma0(x uint32) uint32 { return lowerSigma0(x) }
+
+// LowerSigma1 returns σ1(x).
+func LowerSigma1(x uint32) uint32 { return lowerSigma1(x) }
@@ -0,0 +1,173 @@
+// Animates the JSON lines streamed from /trace.
+"use strict";
+
+const IV = [0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19];
+const NAMES = ["A", "B", "C", "D", "E", "F", "G", "H"];
+
+let events = [];
+let timer = null;
+let paused = false;
+let state = IV.slice();
+let schedule = [];
+
+const $ = (id) => document.getElementById(id);
+const hex = (x) => (x >>> 0).toString(16).toUpperCase().padStart(8, "0");
+const rotr = (n, x) => ((x >>> n) | (x << (32 - n))) >>> 0;
+const shr = (n, x) => x >>> n;
+
+// bit i (0 is the most significant) of x
+const bit = (x, i) => (x >>> (31 - i)) & 1;
+
+// one table row of 32 bit cells; kind(i) returns an extra class for bit i
+function bitRow(name, x, kind, cls) {
+  const tr = document.createElement("tr");
+  if (cls) tr.className = cls;
+  let html = `<td class="name">${name}</td>`;
+  for (let i = 0; i < 32; i++) {
+    const b = bit(x, i);
+    html += `<td class="b${b} ${kind ? kind(i) : ""}">${b}</td>`;
+  }
+  html += `<td class="hex">${hex(x)}</td>`;
+  tr.innerHTML = html;
+  return tr;
+}
+
+// the bits of rotr(n, x) that came around from the low end of x
+const wrapped = (n) => (i) => (i < n ? "wrap" : "");
+// the bits of shr(n, x) that were shifted in as zeros
+const filled = (n) => (i) => (i < n ? "fill" : "");
+
+function sigmaRows(table, label, x, parts) {
+  table.appendChild(bitRow(label + " in", x));
+  let r = 0;
+  for (const [op, n] of parts) {
+    const v = op === "rotr" ? rotr(n, x) : shr(n, x);
+    table.appendChild(bitRow(`${op}${n}`, v, op === "rotr" ? wrapped(n) : filled(n)));
+    r ^= v;
+  }
+  table.appendChild(bitRow(label, r >>> 0, null, "result"));
+}
+
+function showSchedule(t) {
+  const div = $("schedule");
+  div.innerHTML = "";
+  const src = t >= 16 ? [t - 2, t - 7, t - 15, t - 16] : [];
+  schedule.forEach((w, i) => {
+    const cell = document.createElement("div");
+    cell.textContent = `W${i} ${hex(w)}`;
+    if (i === t) cell.className = "cur";
+    else if (src.includes(i)) cell.className = "src";
+    div.appendChild(cell);
+  });
+}
+
+function showRound(ev) {
+  const before = state;
+  $("round").textContent = `t=${ev.t}`;
+  showSchedule(ev.t);
+
+  const reg = $("registers");
+  reg.innerHTML = "";
+  ev.state.forEach((x, i) => {
+    // A and E get new values, the rest shift down from their neighbour
+    reg.appendChild(bitRow(NAMES[i], x, null, i === 0 || i === 4 ? "new" : "moved"));
+  });
+
+  $("sums").textContent =
+    `T1 = H + Σ1(E) + Ch(E,F,G) + K + W = ${hex(before[7])} + ${hex(ev.S1)} + ${hex(ev.ch)} + ${hex(ev.k)} + ${hex(ev.w)} = ${hex(ev.t1)}\n` +
+    `T2 = Σ0(A) + Maj(A,B,C) = ${hex(ev.S0)} + ${hex(ev.maj)} = ${hex(ev.t2)}\n` +
+    `A = T1 + T2, E = D + T1`;
+
+  $("wterms").textContent = ev.t >= 16
+    ? `W${ev.t} = σ1(W${ev.t - 2}) + W${ev.t - 7} + σ0(W${ev.t - 15}) + W${ev.t - 16} = ${hex(ev.s1)} + ${hex(schedule[ev.t - 7])} + ${hex(ev.s0)} + ${hex(schedule[ev.t - 16])}`
+    : `W${ev.t} is word ${ev.t} of the block`;
+
+  const sig = $("sigmas");
+  sig.innerHTML = "";
+  sigmaRows(sig, "Σ0(A)", before[0], [["rotr", 2], ["rotr", 13], ["rotr", 22]]);
+  sigmaRows(sig, "Σ1(E)", before[4], [["rotr", 6], ["rotr", 11], ["rotr", 25]]);
+  if (ev.t >= 16) {
+    sigmaRows(sig, "σ0", schedule[ev.t - 15], [["rotr", 7], ["rotr", 18], ["shr", 3]]);
+    sigmaRows(sig, "σ1", schedule[ev.t - 2], [["rotr", 17], ["rotr", 19], ["shr", 10]]);
+  }
+
+  state = ev.state;
+}
+
+function step() {
+  const ev = events.shift();
+  if (!ev) return false;
+  switch (ev.type) {
+    case "block":
+      schedule = ev.w;
+      $("block").textContent = `(block ${ev.n + 1})`;
+      showSchedule(-1);
+      break;
+    case "round":
+      showRound(ev);
+      break;
+    case "digest":
+      $("digest").textContent += `block ${ev.n + 1}: ` + ev.next.map(hex).join(" ") + "\n";
+      state = ev.next;
+      break;
+    case "done":
+      $("digest").textContent += `\n${ev.digest}\n`;
+      $("status").textContent = "done";
+      return false;
+  }
+  return true;
+}
+
+function run() {
+  clearTimeout(timer);
+  timer = null;
+  if (paused) return;
+  if (step()) {
+    timer = setTimeout(run, 1000 / $("speed").value);
+  }
+}
+
+async function start(e) {
+  e.preventDefault();
+  clearTimeout(timer);
+  timer = null;
+  events = [];
+  state = IV.slice();
+  $("digest").textContent = "";
+  $("status").textContent = "hashing…";
+
+  const q = new URLSearchParams({ m: $("message").value });
+  if ($("hex").checked) q.set("hex", "1");
+  const resp = await fetch("trace?" + q);
+  if (!resp.ok) {
+    $("status").textContent = await resp.text();
+    return;
+  }
+
+  // read the JSON lines as they arrive
+  const reader = resp.body.getReader();
+  const dec = new TextDecoder();
+  let buf = "";
+  for (;;) {
+    const { done, value } = await reader.read();
+    if (done) break;
+    buf += dec.decode(value, { stream: true });
+    const lines = buf.split("\n");
+    buf = lines.pop();
+    for (const l of lines) if (l) events.push(JSON.parse(l));
+    // restart the animation if it caught up with the stream
+    if (timer === null && !paused) run();
+  }
+}
+
+$("form").addEventListener("submit", start);
+$("pause").addEventListener("click", () => {
+  paused = !paused;
+  $("pause").textContent = paused ? "resume" : "pause";
+  run();
+});
+$("step").addEventListener("click", () => {
+  paused = true;
+  $("pause").textContent = "resume";
+  step();
+});
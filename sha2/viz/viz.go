@@ -0,0 +1,124 @@
+// Package viz serves a local web page that animates the SHA-256 message
+// schedule and the rounds of the compression function.
+//
+// The page fetches /trace, which streams one JSON object per line as the
+// hash runs, taken from the sha2.Trace hooks:
+//
+//	{"type":"block","n":0,"w":[...64 words]}
+//	{"type":"round","n":0,"t":0,"w":...,"k":...,"t1":...,"t2":...,"ch":...,"maj":...,"S0":...,"S1":...,"s0":...,"s1":...,"state":[a..h]}
+//	{"type":"digest","n":0,"prev":[...],"work":[...],"next":[...]}
+//	{"type":"done","digest":"ba7816bf..."}
+//
+// Words are sent as numbers.  The static assets are embedded, so no network
+// access beyond localhost is needed.
+package viz
+
+import (
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// MaxMessage is the longest message /trace will hash, in bytes.
+const MaxMessage = 4096
+
+//go:embed static
+var static embed.FS
+
+type blockEvent struct {
+	Type string     `json:"type"`
+	N    int        `json:"n"`
+	W    [64]uint32 `json:"w"`
+}
+
+type roundEvent struct {
+	Type  string    `json:"type"`
+	N     int       `json:"n"`
+	T     int       `json:"t"`
+	W     uint32    `json:"w"`
+	K     uint32    `json:"k"`
+	T1    uint32    `json:"t1"`
+	T2    uint32    `json:"t2"`
+	Ch    uint32    `json:"ch"`
+	Maj   uint32    `json:"maj"`
+	S0    uint32    `json:"S0"`
+	S1    uint32    `json:"S1"`
+	LS0   uint32    `json:"s0"`
+	LS1   uint32    `json:"s1"`
+	State [8]uint32 `json:"state"`
+}
+
+type digestEvent struct {
+	Type string    `json:"type"`
+	N    int       `json:"n"`
+	Prev [8]uint32 `json:"prev"`
+	Work [8]uint32 `json:"work"`
+	Next [8]uint32 `json:"next"`
+}
+
+type doneEvent struct {
+	Type   string `json:"type"`
+	Digest string `json:"digest"`
+}
+
+// Handler returns the handler for the page and its /trace endpoint.
+func Handler() http.Handler {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(sub)))
+	mux.HandleFunc("/trace", serveTrace)
+	return mux
+}
+
+// serveTrace hashes the message in the m query parameter (hex encoded if
+// hex=1) and streams the trace as JSON lines.
+func serveTrace(w http.ResponseWriter, r *http.Request) {
+	m := []byte(r.FormValue("m"))
+	if r.FormValue("hex") == "1" {
+		b, err := hex.DecodeString(string(m))
+		if err != nil {
+			http.Error(w, "bad hex message: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		m = b
+	}
+	if len(m) > MaxMessage {
+		http.Error(w, "message too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	send := func(v interface{}) {
+		enc.Encode(v)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	d := sha2.Sha256Traced(m, &sha2.Trace{
+		Schedule: func(n int, w *[64]uint32) {
+			send(blockEvent{Type: "block", N: n, W: *w})
+		},
+		Round: func(n int, rd sha2.Round) {
+			send(roundEvent{
+				Type: "round", N: n, T: rd.T, W: rd.W, K: rd.K, T1: rd.T1, T2: rd.T2,
+				Ch: rd.Ch, Maj: rd.Maj, S0: rd.UpperSigma0, S1: rd.UpperSigma1,
+				LS0: rd.LowerSigma0, LS1: rd.LowerSigma1, State: rd.State,
+			})
+		},
+		Digest: func(n int, prev, work, next [8]uint32) {
+			send(digestEvent{Type: "digest", N: n, Prev: prev, Work: work, Next: next})
+		},
+	})
+	send(doneEvent{Type: "done", Digest: hex.EncodeToString(d[:])})
+}
@@ -0,0 +1,94 @@
+package viz_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jwatson0/go/gosha256/sha2/viz"
+)
+
+func TestIndex(t *testing.T) {
+	srv := httptest.NewServer(viz.Handler())
+	defer srv.Close()
+
+	for _, path := range []string{"/", "/app.js", "/style.css"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || len(b) == 0 {
+			t.Errorf("GET %s => %d, %d bytes", path, resp.StatusCode, len(b))
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	srv := httptest.NewServer(viz.Handler())
+	defer srv.Close()
+
+	v := []struct {
+		query  string
+		blocks int
+		digest string
+	}{
+		{"m=abc", 1, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"m=616263&hex=1", 1, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"m=abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq", 2, "248d6a61d20638b8e5c026930c3e6039a33ce45964ff2167f6ecedd419db06c1"},
+	}
+	for i, a := range v {
+		resp, err := http.Get(srv.URL + "/trace?" + a.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := map[string]int{}
+		var last struct {
+			Type   string
+			Digest string
+			T      int
+			State  [8]uint32
+		}
+		s := bufio.NewScanner(resp.Body)
+		for s.Scan() {
+			if err := json.Unmarshal(s.Bytes(), &last); err != nil {
+				t.Fatalf("trace #%d: bad line %q: %s", i, s.Text(), err)
+			}
+			counts[last.Type]++
+			// NIST "abc" example, t=0
+			if i == 0 && last.Type == "round" && last.T == 0 && last.State[0] != 0x5D6AEBCD {
+				t.Errorf("trace #%d: round 0 A = %8.8X, want 5D6AEBCD", i, last.State[0])
+			}
+		}
+		resp.Body.Close()
+		if counts["block"] != a.blocks || counts["round"] != 64*a.blocks || counts["digest"] != a.blocks || counts["done"] != 1 {
+			t.Errorf("trace #%d: got events %v", i, counts)
+		}
+		if last.Type != "done" || last.Digest != a.digest {
+			t.Errorf("trace #%d: final event %+v, want digest %s", i, last, a.digest)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/trace?hex=1&m=zz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("trace with bad hex => %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp, err = http.Get(srv.URL + "/trace?m=" + strings.Repeat("a", viz.MaxMessage+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("trace with long message => %d, want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+}
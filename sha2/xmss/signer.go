@@ -0,0 +1,167 @@
+package xmss
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jwatson0/go/gosha256/sha2/internal/fsutil"
+)
+
+// Signer is a PrivateKey kept in a file.  Before each signature is made the
+// index of the next leaf is written to a new file, synced and renamed over
+// the old one, so however the program stops, a leaf that may have signed is
+// never used again.  A crash can at worst lose a leaf.
+//
+// A Signer holds a lock on its key file, in path+".lock", until Close, so
+// that no other Signer, in this process or another, can use the key at the
+// same time.  Where flock is missing, on Windows and Plan 9 among others, a
+// Signer that isn't closed leaves the lock file, which must then be removed
+// by hand.
+type Signer struct {
+	mu     sync.Mutex
+	path   string
+	key    *PrivateKey
+	unlock func() error // nil once closed
+}
+
+var (
+	// ErrLocked is returned when another Signer is using the key file.
+	ErrLocked = errors.New("xmss: key file in use by another signer")
+
+	errClosed = errors.New("xmss: signer closed")
+)
+
+// CreateSigner saves k in a new file at path, which must not exist, and
+// returns a Signer for it.  k must not be used afterwards.
+func CreateSigner(path string, k *PrivateKey) (*Signer, error) {
+	unlock, err := lock(path)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := writeTemp(path, k)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	defer os.Remove(tmp)
+	// unlike a rename, a link doesn't replace an existing key
+	if err := os.Link(tmp, path); err != nil {
+		unlock()
+		return nil, err
+	}
+	if err := fsutil.SyncDir(path); err != nil {
+		unlock()
+		return nil, err
+	}
+	return &Signer{path: path, key: k, unlock: unlock}, nil
+}
+
+// OpenSigner returns a Signer for the key file at path, as ParsePrivateKey.
+func OpenSigner(path string, p *Params) (*Signer, error) {
+	unlock, err := lock(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	k, err := ParsePrivateKey(p, b)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	return &Signer{path: path, key: k, unlock: unlock}, nil
+}
+
+// lock locks the key file at path for a Signer.
+func lock(path string) (func() error, error) {
+	unlock, err := fsutil.Lock(path + ".lock")
+	if err == fsutil.ErrLocked {
+		err = ErrLocked
+	}
+	return unlock, err
+}
+
+// Close releases the key file.  The Signer can't sign afterwards.
+func (s *Signer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unlock == nil {
+		return errClosed
+	}
+	err := s.unlock()
+	s.unlock = nil
+	return err
+}
+
+// Sign saves the key with its next leaf used, then signs msg with that leaf.
+// If the key can't be saved the leaf isn't used, but is still skipped.
+func (s *Signer) Sign(msg []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unlock == nil {
+		return nil, errClosed
+	}
+	idx, err := s.key.reserve()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return s.key.signAt(idx, msg)
+}
+
+// Public returns the public key.
+func (s *Signer) Public() *PublicKey {
+	return s.key.Public()
+}
+
+// Remaining returns the number of signatures the key can still make.
+func (s *Signer) Remaining() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.key.Remaining()
+}
+
+// save replaces the key file atomically.
+func (s *Signer) save() error {
+	tmp, err := writeTemp(s.path, s.key)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return fsutil.SyncDir(s.path)
+}
+
+// writeTemp writes k to a synced temporary file beside path.
+func writeTemp(path string, k *PrivateKey) (string, error) {
+	b, _ := k.MarshalBinary()
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Chmod(0600)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
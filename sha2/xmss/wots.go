@@ -0,0 +1,216 @@
+package xmss
+
+import (
+	"encoding/binary"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+)
+
+// WOTS+ with n = 32 and w = 16: a 256-bit message is 64 base-16 digits, and
+// their checksum 3 more.
+const (
+	n    = 32
+	w    = 16
+	len1 = 64
+	len2 = 3
+	wlen = len1 + len2
+)
+
+// Hash function domain separators, toByte(x, 32) in front of the key.
+const (
+	padF      = 0
+	padH      = 1
+	padHmsg   = 2
+	padPRF    = 3
+	padKeygen = 4
+)
+
+// Address types.
+const (
+	typeOTS      = 0
+	typeLTree    = 1
+	typeHashTree = 2
+)
+
+// address is the 32-byte ADRS of RFC 8391, section 2.5, as eight words:
+//
+//	0  layer address, always 0 in a single tree
+//	1  tree address, high word, always 0
+//	2  tree address, low word, always 0
+//	3  type
+//	4  OTS address, L-tree address or 0
+//	5  chain address or tree height
+//	6  hash address or tree index
+//	7  keyAndMask
+//
+// Each address is built from zero, so words a type doesn't use are 0.
+type address [8]uint32
+
+func (a *address) bytes() []byte {
+	b := make([]byte, 32)
+	for i, v := range a {
+		binary.BigEndian.PutUint32(b[i*4:], v)
+	}
+	return b
+}
+
+// prefix returns the intermediate hash value after the first block of
+// every keyed hash: toByte(pad, 32) || key, key being n bytes.
+func prefix(pad byte, key []byte) [8]uint32 {
+	var b [64]byte
+	b[31] = pad
+	copy(b[32:], key)
+	h := sha2.Sha256InitialHash()
+	sha2.Sha256Compress(&h, b[:])
+	return h
+}
+
+// keyed finishes a keyed hash from its prefix.
+func keyed(h [8]uint32, m ...[]byte) [n]byte {
+	d := sha2.NewFromMidstate(h, 64)
+	for _, b := range m {
+		d.Write(b)
+	}
+	return d.Sum256()
+}
+
+// hash is a keyed hash with an n-byte key: F, H or PRF.
+func hash(pad byte, key []byte, m ...[]byte) [n]byte {
+	return keyed(prefix(pad, key), m...)
+}
+
+// hashMsg is H_msg, whose key is 3n bytes: r || root || toByte(idx, n).
+func hashMsg(r, root *[n]byte, idx uint32, msg []byte) [n]byte {
+	var b [32 + 3*n]byte
+	b[31] = padHmsg
+	copy(b[32:], r[:])
+	copy(b[32+n:], root[:])
+	binary.BigEndian.PutUint32(b[32+3*n-4:], idx)
+	d := sha2.New()
+	d.Write(b[:])
+	d.Write(msg)
+	return d.Sum256()
+}
+
+// seeds holds the midstates of the PRFs keyed by the public seed, for
+// bitmasks and keys, and by the secret seed, for WOTS+ secret keys (the
+// PRF_keygen of NIST SP 800-208).  Their first block is the same for every
+// call, so it is compressed once.
+type seeds struct {
+	pubSeed [n]byte
+	prf     [8]uint32
+	keygen  [8]uint32 // zero in a public key
+}
+
+func newSeeds(pubSeed, skSeed []byte) *seeds {
+	s := &seeds{prf: prefix(padPRF, pubSeed)}
+	copy(s.pubSeed[:], pubSeed)
+	if skSeed != nil {
+		s.keygen = prefix(padKeygen, skSeed)
+	}
+	return s
+}
+
+func (s *seeds) prfAt(a *address) [n]byte {
+	return keyed(s.prf, a.bytes())
+}
+
+func xor(x *[n]byte, m *[n]byte) {
+	for i := range x {
+		x[i] ^= m[i]
+	}
+}
+
+// chain applies the chaining function steps times from start.
+func (s *seeds) chain(x [n]byte, start, steps int, a address) [n]byte {
+	for i := start; i < start+steps; i++ {
+		a[6] = uint32(i)
+		a[7] = 0
+		key := s.prfAt(&a)
+		a[7] = 1
+		bm := s.prfAt(&a)
+		xor(&x, &bm)
+		x = hash(padF, key[:], x[:])
+	}
+	return x
+}
+
+// randHash is RAND_HASH, hashing two nodes with bitmasks.
+func (s *seeds) randHash(left, right [n]byte, a address) [n]byte {
+	a[7] = 0
+	key := s.prfAt(&a)
+	a[7] = 1
+	bm0 := s.prfAt(&a)
+	a[7] = 2
+	bm1 := s.prfAt(&a)
+	xor(&left, &bm0)
+	xor(&right, &bm1)
+	return hash(padH, key[:], left[:], right[:])
+}
+
+func chainAddress(ots uint32, i int) address {
+	return address{3: typeOTS, 4: ots, 5: uint32(i)}
+}
+
+// wotsSK returns secret element i of WOTS+ key ots.
+func (s *seeds) wotsSK(ots uint32, i int) [n]byte {
+	a := chainAddress(ots, i)
+	return keyed(s.keygen, s.pubSeed[:], a.bytes())
+}
+
+// baseW returns the digits of msg and of their checksum.
+func baseW(msg *[n]byte) (d [wlen]int) {
+	csum := 0
+	for i, b := range msg {
+		d[2*i] = int(b >> 4)
+		d[2*i+1] = int(b & 15)
+		csum += 2*(w-1) - d[2*i] - d[2*i+1]
+	}
+	// the 12-bit checksum, shifted left 4 to fill two bytes, in 3 digits
+	csum <<= 4
+	d[len1] = csum >> 12 & 15
+	d[len1+1] = csum >> 8 & 15
+	d[len1+2] = csum >> 4 & 15
+	return d
+}
+
+func (s *seeds) wotsPK(ots uint32) (pk [wlen][n]byte) {
+	for i := range pk {
+		pk[i] = s.chain(s.wotsSK(ots, i), 0, w-1, chainAddress(ots, i))
+	}
+	return pk
+}
+
+func (s *seeds) wotsSign(msg *[n]byte, ots uint32) (sig [wlen][n]byte) {
+	for i, d := range baseW(msg) {
+		sig[i] = s.chain(s.wotsSK(ots, i), 0, d, chainAddress(ots, i))
+	}
+	return sig
+}
+
+func (s *seeds) wotsPKFromSig(sig *[wlen][n]byte, msg *[n]byte, ots uint32) (pk [wlen][n]byte) {
+	for i, d := range baseW(msg) {
+		pk[i] = s.chain(sig[i], d, w-1-d, chainAddress(ots, i))
+	}
+	return pk
+}
+
+// ltree compresses a WOTS+ public key to a leaf.
+func (s *seeds) ltree(pk [wlen][n]byte, ots uint32) [n]byte {
+	a := address{3: typeLTree, 4: ots}
+	for l := wlen; l > 1; l = (l + 1) / 2 {
+		for i := 0; i < l/2; i++ {
+			a[6] = uint32(i)
+			pk[i] = s.randHash(pk[2*i], pk[2*i+1], a)
+		}
+		if l%2 == 1 {
+			pk[l/2] = pk[l-1]
+		}
+		a[5]++
+	}
+	return pk[0]
+}
+
+func nodeAddress(height int, index uint32) address {
+	return address{3: typeHashTree, 5: uint32(height), 6: index}
+}
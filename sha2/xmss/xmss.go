@@ -0,0 +1,302 @@
+// Package xmss implements the XMSS stateful hash-based signatures of RFC
+// 8391 with its SHA2-256 parameter sets: n = 32, w = 16, and trees of 2^10,
+// 2^16 or 2^20 WOTS+ one-time keys.
+//
+// A key pair signs one message per leaf of its tree.  Each WOTS+ public key
+// is compressed to a leaf by an L-tree, and a signature carries the WOTS+
+// signature and the authentication path from its leaf to the root, which is
+// the public key.  The keyed hashes are those of the RFC,
+//
+//	F(KEY, M)     = SHA-256(toByte(0, 32) || KEY || M)
+//	H(KEY, M)     = SHA-256(toByte(1, 32) || KEY || M)
+//	H_msg(KEY, M) = SHA-256(toByte(2, 32) || KEY || M)
+//	PRF(KEY, M)   = SHA-256(toByte(3, 32) || KEY || M)
+//
+// computed with sha2.Sha256Compress: the first block of each is the padding
+// and key, and for the PRFs keyed by the seeds it is compressed once per key
+// and continued from its midstate.  WOTS+ secret keys, which the RFC leaves
+// to the implementation, are PRF_keygen(SK_SEED, PUB_SEED || ADRS) with
+// toByte(4, 32) as in NIST SP 800-208 and the reference implementation.
+//
+// Reusing a leaf breaks the scheme, so a PrivateKey counts the signatures it
+// makes, and a Signer keeps the count in a file, saved before each signature
+// is made.  Keys and signatures are encoded as in the RFC.
+//
+// The RFC itself has no known-answer vectors, and those of the reference
+// implementation are not available to the tests.  Keys and signatures from
+// fixed seeds are checked against testdata/kat.txt, produced by a separate
+// implementation written from the RFC and SP 800-208.
+package xmss
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Params is an XMSS parameter set.
+type Params struct {
+	Name   string
+	OID    uint32 // 0 for a set not in the RFC
+	Height int    // the tree has 2^Height leaves
+}
+
+// The parameter sets of RFC 8391, section 5.3.
+var (
+	SHA2_10_256 = &Params{Name: "XMSS-SHA2_10_256", OID: 1, Height: 10}
+	SHA2_16_256 = &Params{Name: "XMSS-SHA2_16_256", OID: 2, Height: 16}
+	SHA2_20_256 = &Params{Name: "XMSS-SHA2_20_256", OID: 3, Height: 20}
+)
+
+// Lookup returns the parameter set with oid, or nil.
+func Lookup(oid uint32) *Params {
+	for _, p := range []*Params{SHA2_10_256, SHA2_16_256, SHA2_20_256} {
+		if p.OID == oid {
+			return p
+		}
+	}
+	return nil
+}
+
+// Signatures returns the number of signatures a key can make.
+func (p *Params) Signatures() uint64 {
+	return 1 << uint(p.Height)
+}
+
+// SignatureSize returns the size of an encoded signature.
+func (p *Params) SignatureSize() int {
+	return 4 + n + (wlen+p.Height)*n
+}
+
+// Sizes of the encoded keys.
+const (
+	PublicKeySize  = 4 + 2*n
+	PrivateKeySize = 4 + 4 + 4*n
+)
+
+var (
+	// ErrExhausted is returned when a key has made all its signatures.
+	ErrExhausted = errors.New("xmss: all one-time keys used")
+
+	// ErrFormat is returned for a key that can't be decoded.
+	ErrFormat = errors.New("xmss: malformed key")
+)
+
+// PublicKey is the root of the tree and the seed for its bitmasks.
+type PublicKey struct {
+	Params *Params
+	root   [n]byte
+	seeds  *seeds
+}
+
+// PrivateKey signs with the next unused leaf of its tree.
+type PrivateKey struct {
+	Params *Params
+	idx    uint32 // next leaf
+	skSeed [n]byte
+	skPRF  [n]byte
+	root   [n]byte
+	seeds  *seeds
+	prf    [8]uint32 // PRF keyed by SK_PRF, for the message randomizer
+
+	nodes [][][n]byte // the tree, leaves first, built on first use
+}
+
+// GenerateKey returns a new key with seeds read from rand, such as
+// crypto/rand.Reader.  It builds the whole tree, which takes 2^Height WOTS+
+// public keys.
+func GenerateKey(p *Params, rand io.Reader) (*PrivateKey, error) {
+	var seed [3 * n]byte
+	if _, err := io.ReadFull(rand, seed[:]); err != nil {
+		return nil, err
+	}
+	k := newPrivateKey(p, 0, seed[:n], seed[n:2*n], seed[2*n:])
+	k.build()
+	k.root = k.nodes[p.Height][0]
+	return k, nil
+}
+
+func newPrivateKey(p *Params, idx uint32, skSeed, skPRF, pubSeed []byte) *PrivateKey {
+	k := &PrivateKey{Params: p, idx: idx, seeds: newSeeds(pubSeed, skSeed)}
+	copy(k.skSeed[:], skSeed)
+	copy(k.skPRF[:], skPRF)
+	k.prf = prefix(padPRF, skPRF)
+	return k
+}
+
+// build computes every node of the tree.
+func (k *PrivateKey) build() {
+	h := k.Params.Height
+	k.nodes = make([][][n]byte, h+1)
+	leaves := make([][n]byte, 1<<uint(h))
+	for i := range leaves {
+		leaves[i] = k.seeds.ltree(k.seeds.wotsPK(uint32(i)), uint32(i))
+	}
+	k.nodes[0] = leaves
+	for level := 0; level < h; level++ {
+		below := k.nodes[level]
+		above := make([][n]byte, len(below)/2)
+		for j := range above {
+			above[j] = k.seeds.randHash(below[2*j], below[2*j+1], nodeAddress(level, uint32(j)))
+		}
+		k.nodes[level+1] = above
+	}
+}
+
+// Public returns the public key.
+func (k *PrivateKey) Public() *PublicKey {
+	return &PublicKey{Params: k.Params, root: k.root, seeds: newSeeds(k.seeds.pubSeed[:], nil)}
+}
+
+// Remaining returns the number of signatures the key can still make.
+func (k *PrivateKey) Remaining() uint64 {
+	return k.Params.Signatures() - uint64(k.idx)
+}
+
+// reserve takes the next leaf.
+func (k *PrivateKey) reserve() (uint32, error) {
+	if k.Remaining() == 0 {
+		return 0, ErrExhausted
+	}
+	k.idx++
+	return k.idx - 1, nil
+}
+
+// Sign signs msg with the next leaf.  A key must not be copied, or restored
+// from an earlier encoding, as two copies would sign with the same leaves;
+// Signer keeps a key in a file safely.
+func (k *PrivateKey) Sign(msg []byte) ([]byte, error) {
+	idx, err := k.reserve()
+	if err != nil {
+		return nil, err
+	}
+	return k.signAt(idx, msg)
+}
+
+// signAt signs msg with leaf idx.
+func (k *PrivateKey) signAt(idx uint32, msg []byte) ([]byte, error) {
+	if k.nodes == nil {
+		k.build()
+		if k.nodes[k.Params.Height][0] != k.root {
+			return nil, ErrFormat
+		}
+	}
+	var i [n]byte
+	binary.BigEndian.PutUint32(i[n-4:], idx)
+	r := keyed(k.prf, i[:])
+	m := hashMsg(&r, &k.root, idx, msg)
+
+	sig := make([]byte, 4, k.Params.SignatureSize())
+	binary.BigEndian.PutUint32(sig, idx)
+	sig = append(sig, r[:]...)
+	ots := k.seeds.wotsSign(&m, idx)
+	for j := range ots {
+		sig = append(sig, ots[j][:]...)
+	}
+	for level := 0; level < k.Params.Height; level++ {
+		sibling := k.nodes[level][idx>>uint(level)^1]
+		sig = append(sig, sibling[:]...)
+	}
+	return sig, nil
+}
+
+// Verify reports whether sig is a signature of msg by pk.
+func Verify(pk *PublicKey, msg, sig []byte) bool {
+	p := pk.Params
+	if len(sig) != p.SignatureSize() {
+		return false
+	}
+	idx := binary.BigEndian.Uint32(sig)
+	if uint64(idx) >= p.Signatures() {
+		return false
+	}
+	var r [n]byte
+	copy(r[:], sig[4:])
+	m := hashMsg(&r, &pk.root, idx, msg)
+
+	var ots [wlen][n]byte
+	b := sig[4+n:]
+	for j := range ots {
+		copy(ots[j][:], b[j*n:])
+	}
+	b = b[wlen*n:]
+	s := pk.seeds
+	node := s.ltree(s.wotsPKFromSig(&ots, &m, idx), idx)
+	for level := 0; level < p.Height; level++ {
+		var auth [n]byte
+		copy(auth[:], b[level*n:])
+		a := nodeAddress(level, idx>>uint(level+1))
+		if idx>>uint(level)&1 == 0 {
+			node = s.randHash(node, auth, a)
+		} else {
+			node = s.randHash(auth, node, a)
+		}
+	}
+	return node == pk.root
+}
+
+// MarshalBinary encodes the key as OID || root || SEED.
+func (pk *PublicKey) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 4, PublicKeySize)
+	binary.BigEndian.PutUint32(b, pk.Params.OID)
+	b = append(b, pk.root[:]...)
+	return append(b, pk.seeds.pubSeed[:]...), nil
+}
+
+// ParsePublicKey decodes a key encoded by MarshalBinary.  If p is nil the
+// parameter set is looked up by its OID; otherwise the OID must be p's.
+func ParsePublicKey(p *Params, b []byte) (*PublicKey, error) {
+	if len(b) != PublicKeySize {
+		return nil, ErrFormat
+	}
+	p, err := params(p, binary.BigEndian.Uint32(b))
+	if err != nil {
+		return nil, err
+	}
+	pk := &PublicKey{Params: p, seeds: newSeeds(b[4+n:], nil)}
+	copy(pk.root[:], b[4:])
+	return pk, nil
+}
+
+func params(p *Params, oid uint32) (*Params, error) {
+	if p == nil {
+		p = Lookup(oid)
+	}
+	if p == nil || p.OID != oid {
+		return nil, ErrFormat
+	}
+	return p, nil
+}
+
+// MarshalBinary encodes the key, with the index of its next leaf, as
+// OID || idx || SK_SEED || SK_PRF || root || SEED, the layout of the
+// reference implementation.
+func (k *PrivateKey) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 8, PrivateKeySize)
+	binary.BigEndian.PutUint32(b, k.Params.OID)
+	binary.BigEndian.PutUint32(b[4:], k.idx)
+	b = append(b, k.skSeed[:]...)
+	b = append(b, k.skPRF[:]...)
+	b = append(b, k.root[:]...)
+	return append(b, k.seeds.pubSeed[:]...), nil
+}
+
+// ParsePrivateKey decodes a key encoded by MarshalBinary, as ParsePublicKey.
+// Its tree is rebuilt, and checked against its root, when it first signs.
+func ParsePrivateKey(p *Params, b []byte) (*PrivateKey, error) {
+	if len(b) != PrivateKeySize {
+		return nil, ErrFormat
+	}
+	p, err := params(p, binary.BigEndian.Uint32(b))
+	if err != nil {
+		return nil, err
+	}
+	idx := binary.BigEndian.Uint32(b[4:])
+	if uint64(idx) > p.Signatures() {
+		return nil, ErrFormat
+	}
+	b = b[8:]
+	k := newPrivateKey(p, idx, b[:n], b[n:2*n], b[3*n:])
+	copy(k.root[:], b[2*n:])
+	return k, nil
+}
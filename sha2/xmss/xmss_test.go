@@ -0,0 +1,267 @@
+package xmss_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jwatson0/go/gosha256/sha2"
+	"github.com/jwatson0/go/gosha256/sha2/xmss"
+)
+
+// a small tree, not one of the RFC's, to keep the tests quick
+var small = &xmss.Params{Name: "test-h4", Height: 4}
+
+func TestSign(t *testing.T) {
+	k, err := xmss.GenerateKey(small, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := k.Public()
+	msg := []byte("release v1.2.3")
+	var sigs [][]byte
+	for i := uint64(0); i < small.Signatures(); i++ {
+		sig, err := k.Sign(msg)
+		if err != nil {
+			t.Fatalf("Sign #%d => %v", i, err)
+		}
+		if len(sig) != small.SignatureSize() {
+			t.Fatalf("signature is %d bytes, want %d", len(sig), small.SignatureSize())
+		}
+		if !xmss.Verify(pk, msg, sig) {
+			t.Errorf("Verify(signature #%d) => false", i)
+		}
+		sigs = append(sigs, sig)
+	}
+	if bytes.Equal(sigs[0], sigs[1]) {
+		t.Errorf("two leaves gave the same signature")
+	}
+	if _, err := k.Sign(msg); err != xmss.ErrExhausted {
+		t.Errorf("Sign after %d signatures => %v, want ErrExhausted", small.Signatures(), err)
+	}
+
+	// tampering with the message, the index, the randomizer, the WOTS+
+	// signature or the authentication path is caught
+	if xmss.Verify(pk, []byte("release v1.2.4"), sigs[5]) {
+		t.Errorf("Verify(other message) => true")
+	}
+	for _, i := range []int{3, 4, 36, 36 + 67*32 - 1, len(sigs[5]) - 1} {
+		bad := append([]byte(nil), sigs[5]...)
+		bad[i] ^= 1
+		if xmss.Verify(pk, msg, bad) {
+			t.Errorf("Verify(signature with byte %d changed) => true", i)
+		}
+	}
+	if xmss.Verify(pk, msg, sigs[5][:len(sigs[5])-1]) {
+		t.Errorf("Verify(short signature) => true")
+	}
+	other, _ := xmss.GenerateKey(small, rand.Reader)
+	if xmss.Verify(other.Public(), msg, sigs[5]) {
+		t.Errorf("Verify(another key) => true")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	k, _ := xmss.GenerateKey(small, rand.Reader)
+	k.Sign(nil)
+	b, _ := k.MarshalBinary()
+	if len(b) != xmss.PrivateKeySize {
+		t.Errorf("private key is %d bytes, want %d", len(b), xmss.PrivateKeySize)
+	}
+	k2, err := xmss.ParsePrivateKey(small, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k2.Remaining() != k.Remaining() {
+		t.Errorf("decoded key has %d signatures left, want %d", k2.Remaining(), k.Remaining())
+	}
+
+	pb, _ := k.Public().MarshalBinary()
+	pk, err := xmss.ParsePublicKey(small, pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the decoded key rebuilds the same tree
+	sig, err := k2.Sign([]byte("m"))
+	if err != nil || !xmss.Verify(pk, []byte("m"), sig) {
+		t.Errorf("signature by decoded key => %v, doesn't verify", err)
+	}
+
+	if _, err := xmss.ParsePublicKey(nil, pb); err != xmss.ErrFormat {
+		t.Errorf("ParsePublicKey(unknown OID) => %v, want ErrFormat", err)
+	}
+	pb[0] = 0xff
+	if _, err := xmss.ParsePublicKey(small, pb); err != xmss.ErrFormat {
+		t.Errorf("ParsePublicKey(wrong OID) => %v, want ErrFormat", err)
+	}
+	if _, err := xmss.ParsePrivateKey(small, b[1:]); err != xmss.ErrFormat {
+		t.Errorf("ParsePrivateKey(short) => %v, want ErrFormat", err)
+	}
+
+	// a private key whose root doesn't match its seeds refuses to sign
+	b[len(b)-33] ^= 1
+	k3, _ := xmss.ParsePrivateKey(small, b)
+	if _, err := k3.Sign(nil); err != xmss.ErrFormat {
+		t.Errorf("Sign with a corrupt key => %v, want ErrFormat", err)
+	}
+}
+
+func TestSigner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xmss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "key")
+
+	k, _ := xmss.GenerateKey(small, rand.Reader)
+	s, err := xmss.CreateSigner(path, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := xmss.CreateSigner(path, k); err != xmss.ErrLocked {
+		t.Errorf("CreateSigner(key in use) => %v, want ErrLocked", err)
+	}
+	if _, err := xmss.OpenSigner(path, small); err != xmss.ErrLocked {
+		t.Errorf("OpenSigner(key in use) => %v, want ErrLocked", err)
+	}
+	pk := s.Public()
+	first, err := s.Sign([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Sign([]byte("c")); err == nil {
+		t.Error("Sign after Close succeeded")
+	}
+	if _, err := xmss.CreateSigner(path, k); !os.IsExist(err) {
+		t.Errorf("CreateSigner(existing file) => %v, want it to exist", err)
+	}
+
+	// the file already records the leaf used, so reopening it, as after a
+	// crash, goes on with the next one
+	s2, err := xmss.OpenSigner(path, small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s2.Remaining() != small.Signatures()-1 {
+		t.Errorf("reopened key has %d signatures left, want %d", s2.Remaining(), small.Signatures()-1)
+	}
+	second, err := s2.Sign([]byte("b"))
+	if err != nil || !xmss.Verify(pk, []byte("b"), second) {
+		t.Fatalf("Sign after reopening => %v, doesn't verify", err)
+	}
+	if bytes.Equal(first[:4], second[:4]) {
+		t.Errorf("reopened key reused leaf %x", first[:4])
+	}
+	if err := s2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, name := range names {
+		if b := filepath.Base(name); b != "key" && b != "key.lock" {
+			t.Errorf("file %q left beside the key", name)
+		}
+	}
+}
+
+func TestStandardKey(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a tree of 1024 leaves")
+	}
+	k, err := xmss.GenerateKey(xmss.SHA2_10_256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, _ := k.Sign([]byte("m"))
+	pb, _ := k.Public().MarshalBinary()
+	pk, err := xmss.ParsePublicKey(nil, pb)
+	if err != nil || pk.Params != xmss.SHA2_10_256 {
+		t.Fatalf("ParsePublicKey => %v, %v", pk, err)
+	}
+	if len(sig) != 2500 || !xmss.Verify(pk, []byte("m"), sig) {
+		t.Errorf("XMSS-SHA2_10_256 signature of %d bytes doesn't verify", len(sig))
+	}
+}
+
+// TestKnownAnswers checks keys and signatures made from fixed seeds against
+// testdata/kat.txt, which a separate implementation produced.
+func TestKnownAnswers(t *testing.T) {
+	f, err := os.Open("testdata/kat.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var p *xmss.Params // nil while a parameter set is skipped
+	var k *xmss.PrivateKey
+	var msg []byte
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		i := strings.Index(line, " = ")
+		if i < 0 {
+			t.Fatalf("malformed line %q", line)
+		}
+		name, value := line[:i], line[i+3:]
+		if name == "Params" {
+			p, k = nil, nil
+			for _, q := range []*xmss.Params{small, xmss.SHA2_10_256, xmss.SHA2_16_256, xmss.SHA2_20_256} {
+				if q.Name == value {
+					p = q
+				}
+			}
+			if p == nil {
+				t.Fatalf("unknown parameter set %q", value)
+			}
+			if p != small && testing.Short() {
+				p = nil
+			}
+			continue
+		}
+		if p == nil {
+			continue
+		}
+		b, err := hex.DecodeString(value)
+		if err != nil {
+			t.Fatalf("malformed line %q", line)
+		}
+		switch name {
+		case "Seed":
+			if k, err = xmss.GenerateKey(p, bytes.NewReader(b)); err != nil {
+				t.Fatal(err)
+			}
+		case "PublicKey":
+			if got, _ := k.Public().MarshalBinary(); !bytes.Equal(got, b) {
+				t.Errorf("%s: public key %x, want %x", p.Name, got, b)
+			}
+		case "Message":
+			msg = b
+		case "SignatureSHA256":
+			sig, err := k.Sign(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sha2.Sha256(sig); !bytes.Equal(got[:], b) {
+				t.Errorf("%s: signature of %q has SHA-256 %x, want %x", p.Name, msg, got, b)
+			}
+		default:
+			t.Fatalf("unknown field %q", name)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
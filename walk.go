@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// collect expands the paths named on the command line into the files to
+// hash.  Named files are always included, whatever their type, so pipes
+// and devices can be hashed on request.  Directories are walked when
+// recursive is set; the walk skips special files and, unless follow is
+// set, symbolic links.  The files found under each named directory are
+// sorted, so the output doesn't depend on the order of directory entries.
+func collect(paths []string, recursive, follow bool, report func(string, error)) []string {
+	var files []string
+	for _, p := range paths {
+		if p == "-" {
+			files = append(files, p)
+			continue
+		}
+		fi, err := os.Stat(p)
+		if err != nil {
+			report(p, err)
+			continue
+		}
+		if !fi.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		if !recursive {
+			report(p, fmt.Errorf("%s: is a directory", p))
+			continue
+		}
+		var found []string
+		walk(p, []os.FileInfo{fi}, follow, &found, report)
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files
+}
+
+// walk adds the regular files under dir to files.  parents holds the
+// directories on the way down, to stop symbolic link loops.
+func walk(dir string, parents []os.FileInfo, follow bool, files *[]string, report func(string, error)) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		report(dir, err)
+	}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		mode := e.Mode() & os.ModeType
+
+		if mode&os.ModeSymlink != 0 {
+			if !follow {
+				continue
+			}
+			fi, err := os.Stat(p)
+			if err != nil {
+				report(p, err)
+				continue
+			}
+			mode = fi.Mode() & os.ModeType
+		}
+
+		switch {
+		case mode.IsRegular():
+			*files = append(*files, p)
+		case mode.IsDir():
+			fi, err := os.Stat(p)
+			if err != nil {
+				report(p, err)
+				continue
+			}
+			if loops(fi, parents) {
+				report(p, fmt.Errorf("%s: directory loop", p))
+				continue
+			}
+			walk(p, append(parents, fi), follow, files, report)
+		default:
+			// devices, pipes and sockets
+		}
+	}
+}
+
+func loops(fi os.FileInfo, parents []os.FileInfo) bool {
+	for _, p := range parents {
+		if os.SameFile(fi, p) {
+			return true
+		}
+	}
+	return false
+}